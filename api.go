@@ -1,6 +1,7 @@
 package gossip
 
 import (
+	"context"
 	"time"
 
 	"github.com/libopenstorage/gossip/proto"
@@ -63,6 +64,11 @@ type Gossiper interface {
 	// To join an existing cluster provide atleast one ip of the known node.
 	Start(startConfiguration types.GossipStartConfiguration) error
 
+	// StartWithContext begins the gossip protocol like Start. The context
+	// bounds the start and does not affect gossip once it has started.
+	// A gossiper can be started again after it is stopped.
+	StartWithContext(ctx context.Context, startConfiguration types.GossipStartConfiguration) error
+
 	// GossipInterval gets the gossip interval
 	GossipInterval() time.Duration
 
@@ -70,9 +76,23 @@ type Gossiper interface {
 	// required to successfully broadcast the leave message to all other nodes.
 	Stop(leaveTimeout time.Duration) error
 
+	// StopWithReason gracefully stops the gossiping like Stop and lets the
+	// other nodes know the reason for leaving. Other nodes mark this node
+	// as left instead of down if a leave timeout is specified.
+	StopWithReason(leaveTimeout time.Duration, reason string) error
+
+	// StopWithContext stops the gossiping like StopWithReason and waits
+	// for all the background go routines to exit. The context bounds the
+	// wait.
+	StopWithContext(ctx context.Context, leaveTimeout time.Duration, reason string) error
+
 	// GetNodes returns a list of the connection addresses
 	GetNodes() []string
 
+	// Members returns the nodes known to memberlist or the gossip store
+	// which match the given filter, sorted by node id
+	Members(filter types.MemberFilter) []types.Member
+
 	// UpdateCluster updates gossip with latest peer nodes info
 	UpdateCluster(map[types.NodeId]types.NodeUpdate)
 
@@ -83,15 +103,98 @@ type Gossiper interface {
 
 	// UpdateClusterDomainsActiveMap updates the cluster domain active map
 	// All the nodes in an inactive domain will shoot themselves down
-	// and will not participate in quorum decisions
+	// and will not participate in quorum decisions. It overrides the
+	// domain activation policy till the policy is resumed.
 	UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap) error
 
+	// ResumeDomainActivationPolicy lets the domain activation policy manage
+	// the active map again after it was overridden
+	ResumeDomainActivationPolicy() error
+
 	// UpdateSelfClusterDomain updates this node's cluster domain
 	UpdateSelfClusterDomain(selfFailureDomain string)
 
 	// Ping pings the given node's ip:port
-	// Note: This API is only supported with Gossip Version v2 and higher
+	// Note: This API is only supported with Gossip Version v2 and higher.
+	// It returns an error if the gossip version negotiated with the node
+	// is lower.
 	Ping(nodeId types.NodeId, ipPort string) (time.Duration, error)
+
+	// EnterMaintenance puts this node in maintenance. Nodes in maintenance
+	// are not considered in quorum decisions and their leaving the cluster
+	// is not treated as a failure.
+	EnterMaintenance() error
+
+	// ExitMaintenance takes this node out of maintenance
+	ExitMaintenance() error
+
+	// GetReachabilityReport returns the cluster wide reachability matrix
+	// built from the views gossiped by all the nodes along with the
+	// suspected partition groups
+	GetReachabilityReport() types.ReachabilityReport
+
+	// UpdateSelfLabels replaces the key/value labels of this node. The
+	// labels are published to the other nodes in the memberlist meta data.
+	UpdateSelfLabels(labels map[string]string) error
+
+	// GetNodesByLabel returns the ids of the nodes which have all the
+	// key/value labels in the given selector
+	GetNodesByLabel(selector map[string]string) []types.NodeId
+
+	// GetAdvertiseAddr returns the ip:port address advertised to the
+	// other nodes
+	GetAdvertiseAddr() string
+
+	// UpdateAdvertiseAddr changes the ip:port address advertised to the
+	// other nodes. While gossiping, the new address is re-announced and
+	// memberlist is restarted at the new address, binding to it if it was
+	// bound to the old one. The other nodes see this node leave and join
+	// again, after which they update their view of its address. Start sets
+	// the address again from its configuration.
+	UpdateAdvertiseAddr(addr string) error
+
+	// GetClusterVersions returns the gossip version and the range of
+	// compatible versions of this node and of the peers it has heard from
+	GetClusterVersions() map[types.NodeId]types.NodeVersion
+
+	// GetClusterGossipVersion returns the highest gossip version supported
+	// by all the nodes. It returns an empty string if there is none.
+	GetClusterGossipVersion() string
+
+	// MigrateClusterId switches this node to a new cluster id. The node
+	// advertises the new cluster id and keeps gossiping with the nodes
+	// which use the old cluster id till the window closes. The nodes which
+	// still use the old cluster id are then removed from the gossip store
+	// and their gossip is refused.
+	MigrateClusterId(clusterId string, window time.Duration) error
+
+	// GetClusterIdMigrationStatus returns the progress of the last cluster
+	// id migration started on this node
+	GetClusterIdMigrationStatus() types.ClusterIdMigrationStatus
+
+	// GetFederatedClusters returns the summaries of the clusters in the
+	// federation keyed by their cluster ids, including our own cluster.
+	// Nodes which are not gateways only return the summary of their cluster.
+	GetFederatedClusters() map[string]types.ClusterSummary
+
+	// ExplainQuorum returns how the quorum provider decides whether this
+	// node is in quorum as per the current state of the cluster. It does
+	// not change the state of the node or the quorum provider.
+	ExplainQuorum() types.QuorumExplanation
+
+	// GetStateHistory returns the last state transitions of this node
+	// from the oldest to the latest
+	GetStateHistory() []types.StateTransition
+
+	// RegisterFenceHook registers a hook which is invoked when this node
+	// drops out of quorum or its cluster domain is deactivated, and again
+	// when the node regains quorum. The hooks are invoked in the order
+	// they were registered. A node is not fenced till it is up for the
+	// first time after gossip started.
+	RegisterFenceHook(hook types.FenceHook) error
+
+	// UnregisterFenceHook removes a fencing hook
+	UnregisterFenceHook(name string) error
 }
 
 // New returns an initialized Gossip node
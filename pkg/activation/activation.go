@@ -0,0 +1,81 @@
+package activation
+
+import (
+	"fmt"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// domainState returns the state of a domain as per its alive members
+func domainState(health types.DomainHealth) types.ClusterDomainState {
+	if health.AliveMembers > 0 {
+		return types.CLUSTER_DOMAIN_STATE_ACTIVE
+	}
+	return types.CLUSTER_DOMAIN_STATE_INACTIVE
+}
+
+type preferredDomainPolicy struct {
+	preferred string
+}
+
+// NewPreferredDomainPolicy returns a policy which always keeps the preferred
+// domain active and deactivates the other domains when none of their quorum
+// members are alive. The nodes outside the preferred domain never
+// deactivate it, so that both the sides of a partition do not stay in
+// quorum. A failure of the preferred domain needs a manual override.
+func NewPreferredDomainPolicy(preferred string) types.DomainActivationPolicy {
+	return &preferredDomainPolicy{preferred: preferred}
+}
+
+func (p *preferredDomainPolicy) ActiveMap(
+	health map[string]types.DomainHealth,
+) types.ClusterDomainsActiveMap {
+	activeMap := make(types.ClusterDomainsActiveMap)
+	for domain, domainHealth := range health {
+		if domain == p.preferred {
+			activeMap[domain] = types.CLUSTER_DOMAIN_STATE_ACTIVE
+		} else if domainHealth.QuorumMembers > 0 {
+			activeMap[domain] = domainState(domainHealth)
+		}
+	}
+	return activeMap
+}
+
+func (p *preferredDomainPolicy) String() string {
+	return fmt.Sprintf("preferred-domain(%v)", p.preferred)
+}
+
+type witnessMajorityPolicy struct{}
+
+// NewWitnessMajorityPolicy returns a policy which lets the side of the
+// cluster that can see a majority of the witnesses decide. On that side
+// the domains with alive quorum members are active and the other domains
+// are deactivated. A node which cannot see a majority of the witnesses
+// keeps its active map.
+func NewWitnessMajorityPolicy() types.DomainActivationPolicy {
+	return &witnessMajorityPolicy{}
+}
+
+func (w *witnessMajorityPolicy) ActiveMap(
+	health map[string]types.DomainHealth,
+) types.ClusterDomainsActiveMap {
+	witnesses, aliveWitnesses := 0, 0
+	for _, domainHealth := range health {
+		witnesses += domainHealth.Witnesses
+		aliveWitnesses += domainHealth.AliveWitnesses
+	}
+	if aliveWitnesses <= witnesses/2 {
+		return nil
+	}
+	activeMap := make(types.ClusterDomainsActiveMap)
+	for domain, domainHealth := range health {
+		if domainHealth.QuorumMembers > 0 {
+			activeMap[domain] = domainState(domainHealth)
+		}
+	}
+	return activeMap
+}
+
+func (w *witnessMajorityPolicy) String() string {
+	return "witness-majority"
+}
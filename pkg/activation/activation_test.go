@@ -0,0 +1,54 @@
+package activation
+
+import (
+	"testing"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferredDomainPolicy(t *testing.T) {
+	p := NewPreferredDomainPolicy("zone1")
+	health := map[string]types.DomainHealth{
+		"zone1": {Active: true, QuorumMembers: 2, AliveMembers: 2},
+		"zone2": {Active: true, QuorumMembers: 2, AliveMembers: 1},
+		"zone3": {Active: true},
+	}
+	require.Equal(t, types.ClusterDomainsActiveMap{
+		"zone1": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		"zone2": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+	}, p.ActiveMap(health))
+
+	// A failed domain other than the preferred one is deactivated
+	health["zone2"] = types.DomainHealth{Active: true, QuorumMembers: 2}
+	require.Equal(t, types.CLUSTER_DOMAIN_STATE_INACTIVE, p.ActiveMap(health)["zone2"])
+
+	// The preferred domain is never deactivated
+	health["zone1"] = types.DomainHealth{Active: true, QuorumMembers: 2}
+	health["zone2"] = types.DomainHealth{Active: true, QuorumMembers: 2, AliveMembers: 2}
+	activeMap := p.ActiveMap(health)
+	require.Equal(t, types.CLUSTER_DOMAIN_STATE_ACTIVE, activeMap["zone1"])
+	require.Equal(t, types.CLUSTER_DOMAIN_STATE_ACTIVE, activeMap["zone2"])
+}
+
+func TestWitnessMajorityPolicy(t *testing.T) {
+	w := NewWitnessMajorityPolicy()
+	health := map[string]types.DomainHealth{
+		"zone1":   {Active: true, QuorumMembers: 2, AliveMembers: 2},
+		"zone2":   {Active: true, QuorumMembers: 2},
+		"witness": {Active: true, QuorumMembers: 1, AliveMembers: 1, Witnesses: 1, AliveWitnesses: 1},
+	}
+	require.Equal(t, types.ClusterDomainsActiveMap{
+		"zone1":   types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		"zone2":   types.CLUSTER_DOMAIN_STATE_INACTIVE,
+		"witness": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+	}, w.ActiveMap(health))
+
+	// The side which cannot see the witness does not decide
+	health["witness"] = types.DomainHealth{Active: true, QuorumMembers: 1, Witnesses: 1}
+	require.Nil(t, w.ActiveMap(health))
+
+	// There is no decision without witnesses
+	delete(health, "witness")
+	require.Nil(t, w.ActiveMap(health))
+}
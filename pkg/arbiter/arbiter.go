@@ -0,0 +1,141 @@
+package arbiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// lease is the tie-breaker lease granted by an arbiter
+type lease struct {
+	Holders []string
+	Expiry  time.Time
+}
+
+// heldBy returns true if the side shares a node with the holders
+func (l *lease) heldBy(side []string) bool {
+	for _, holder := range l.Holders {
+		for _, id := range side {
+			if holder == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grant renews the lease if it is owned by the side or grants it to the
+// side if it is free. It returns false if another side owns the lease.
+func (l *lease) grant(side []string, ttl time.Duration, now time.Time) bool {
+	if len(l.Holders) > 0 && !l.heldBy(side) && now.Before(l.Expiry) {
+		return false
+	}
+	l.Holders = append([]string{}, side...)
+	l.Expiry = now.Add(ttl)
+	return true
+}
+
+type memoryArbiter struct {
+	lock  sync.Mutex
+	lease lease
+}
+
+// NewMemoryArbiter returns an Arbiter which keeps the lease in memory. It
+// can only break ties between gossipers running in the same process.
+func NewMemoryArbiter() types.Arbiter {
+	return &memoryArbiter{}
+}
+
+func (m *memoryArbiter) Acquire(side []string, ttl time.Duration) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lease.grant(side, ttl, time.Now()), nil
+}
+
+func (m *memoryArbiter) Release(side []string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.lease.heldBy(side) {
+		m.lease = lease{}
+	}
+	return nil
+}
+
+func (m *memoryArbiter) String() string {
+	return "memory"
+}
+
+type fileArbiter struct {
+	path string
+}
+
+// NewFileArbiter returns an Arbiter which keeps the lease in a file. The
+// file is locked while the lease is updated, so that the processes sharing
+// the file can break ties.
+func NewFileArbiter(path string) types.Arbiter {
+	return &fileArbiter{path: path}
+}
+
+// update locks the lease file and writes back the lease modified by fn
+func (f *fileArbiter) update(fn func(l *lease) bool) (bool, error) {
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return false, fmt.Errorf("failed to lock %v: %v", f.path, err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return false, err
+	}
+	var l lease
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &l); err != nil {
+			return false, fmt.Errorf("failed to parse %v: %v", f.path, err)
+		}
+	}
+	if !fn(&l) {
+		return false, nil
+	}
+	if data, err = json.Marshal(l); err != nil {
+		return false, err
+	}
+	if err := file.Truncate(0); err != nil {
+		return false, err
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return false, err
+	}
+	return true, file.Sync()
+}
+
+func (f *fileArbiter) Acquire(side []string, ttl time.Duration) (bool, error) {
+	return f.update(func(l *lease) bool {
+		return l.grant(side, ttl, time.Now())
+	})
+}
+
+func (f *fileArbiter) Release(side []string) error {
+	_, err := f.update(func(l *lease) bool {
+		if !l.heldBy(side) {
+			return false
+		}
+		*l = lease{}
+		return true
+	})
+	return err
+}
+
+func (f *fileArbiter) String() string {
+	return "file " + f.path
+}
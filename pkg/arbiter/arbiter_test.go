@@ -0,0 +1,77 @@
+package arbiter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	side1 = []string{"n0", "n1"}
+	side2 = []string{"n2", "n3"}
+)
+
+func testArbiter(t *testing.T, a types.Arbiter) {
+	acquired, err := a.Acquire(side1, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.True(t, acquired, "Expected free lease to be acquired")
+
+	acquired, err = a.Acquire(side2, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.False(t, acquired, "Expected lease held by another side")
+
+	// The holder renews the lease
+	acquired, err = a.Acquire(side1, 200*time.Millisecond)
+	require.NoError(t, err, "Failed to renew lease")
+	require.True(t, acquired, "Expected lease to be renewed")
+
+	// An expired lease can be taken over
+	time.Sleep(300 * time.Millisecond)
+	acquired, err = a.Acquire(side2, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.True(t, acquired, "Expected expired lease to be acquired")
+
+	// Only the holder can release the lease
+	require.NoError(t, a.Release(side1), "Failed to release lease")
+	acquired, err = a.Acquire(side1, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.False(t, acquired, "Expected lease held by another side")
+	require.NoError(t, a.Release(side2), "Failed to release lease")
+	acquired, err = a.Acquire(side1, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.True(t, acquired, "Expected released lease to be acquired")
+
+	// Any side sharing a node with the holder renews the lease
+	acquired, err = a.Acquire([]string{"n1", "n4"}, time.Second)
+	require.NoError(t, err, "Failed to renew lease")
+	require.True(t, acquired, "Expected lease to be renewed by a changed side")
+	acquired, err = a.Acquire(side1, time.Second)
+	require.NoError(t, err, "Failed to renew lease")
+	require.True(t, acquired, "Expected lease to be renewed by a changed side")
+	acquired, err = a.Acquire([]string{"n4"}, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.False(t, acquired, "Expected lease held by another side")
+}
+
+func TestMemoryArbiter(t *testing.T) {
+	testArbiter(t, NewMemoryArbiter())
+}
+
+func TestFileArbiter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbiter")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lease")
+	testArbiter(t, NewFileArbiter(path))
+
+	// The lease is shared through the file
+	acquired, err := NewFileArbiter(path).Acquire(side2, time.Second)
+	require.NoError(t, err, "Failed to acquire lease")
+	require.False(t, acquired, "Expected lease held by another side")
+}
@@ -0,0 +1,212 @@
+package damping
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+const (
+	// minPenalty is the penalty below which a client is forgotten
+	minPenalty = 1
+)
+
+// Damping is an interface that defines a set of APIs to manage clients which
+// flap. Every flap adds a penalty to the client's score which decays over
+// time. A client whose score goes above the suppress threshold is suppressed
+// till its score decays below the reuse threshold, after which it is passed
+// on to the registered CallbackFn
+type Damping interface {
+	// Flap records a flap for a client. It returns true if the client
+	// is suppressed.
+	Flap(clientID string) bool
+	// IsSuppressed checks if a client is suppressed
+	IsSuppressed(clientID string) bool
+	// Penalty returns the current penalty score of a client
+	Penalty(clientID string) float64
+	// Remove removes a client from the damping list
+	Remove(clientID string)
+	// Start starts monitoring the suppressed clients every checkInterval
+	Start() error
+	// Stop stops monitoring the suppressed clients. It waits for the
+	// CallbackFn in progress to return.
+	Stop() error
+}
+
+// CallbackFn will be executed for every suppressed client whose penalty
+// decays below the reuse threshold
+type CallbackFn func(clientID string) error
+
+type client struct {
+	penalty    float64
+	updatedTs  time.Time
+	suppressed bool
+}
+
+type damping struct {
+	name          string
+	config        types.FlapDampingConfig
+	checkInterval time.Duration
+	clients       map[string]*client
+	mutex         sync.Mutex
+	dcf           CallbackFn
+	stopCh        chan struct{}
+	// monitorDone is closed once the monitor go routine exits
+	monitorDone chan struct{}
+	now         func() time.Time
+}
+
+// NewDampingManager returns the default implementation of Damping interface
+// It takes in a name to be associated with this damping manager, the damping
+// configuration and a CallbackFn
+func NewDampingManager(
+	name string,
+	config types.FlapDampingConfig,
+	checkInterval time.Duration,
+	dcf CallbackFn,
+) Damping {
+	return NewDampingManagerWithClock(name, config, checkInterval, dcf, time.Now)
+}
+
+// NewDampingManagerWithClock returns the default implementation of Damping
+// interface which uses the given clock to decay the penalties
+func NewDampingManagerWithClock(
+	name string,
+	config types.FlapDampingConfig,
+	checkInterval time.Duration,
+	dcf CallbackFn,
+	now func() time.Time,
+) Damping {
+	return &damping{
+		name:          name,
+		config:        config,
+		checkInterval: checkInterval,
+		clients:       make(map[string]*client),
+		dcf:           dcf,
+		now:           now,
+	}
+}
+
+// decay brings the client's penalty up to date
+func (d *damping) decay(c *client, now time.Time) {
+	elapsed := now.Sub(c.updatedTs)
+	if elapsed > 0 && d.config.HalfLife > 0 {
+		c.penalty = c.penalty * math.Pow(0.5, float64(elapsed)/float64(d.config.HalfLife))
+	}
+	c.updatedTs = now
+}
+
+func (d *damping) Flap(clientID string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	c, ok := d.clients[clientID]
+	if !ok {
+		c = &client{updatedTs: now}
+		d.clients[clientID] = c
+	}
+	d.decay(c, now)
+	c.penalty = c.penalty + d.config.Penalty
+	if d.config.MaxPenalty > 0 && c.penalty > d.config.MaxPenalty {
+		c.penalty = d.config.MaxPenalty
+	}
+	if c.penalty >= d.config.SuppressThreshold {
+		c.suppressed = true
+	}
+	return c.suppressed
+}
+
+func (d *damping) IsSuppressed(clientID string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	c, ok := d.clients[clientID]
+	return ok && c.suppressed
+}
+
+func (d *damping) Penalty(clientID string) float64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	c, ok := d.clients[clientID]
+	if !ok {
+		return 0
+	}
+	d.decay(c, d.now())
+	return c.penalty
+}
+
+func (d *damping) Remove(clientID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.clients, clientID)
+}
+
+func (d *damping) Start() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stopCh != nil {
+		return fmt.Errorf("damping manager %v already started", d.name)
+	}
+	d.stopCh = make(chan struct{})
+	d.monitorDone = make(chan struct{})
+	go func(stopCh, monitorDone chan struct{}) {
+		defer close(monitorDone)
+		d.monitor(stopCh)
+	}(d.stopCh, d.monitorDone)
+	return nil
+}
+
+func (d *damping) Stop() error {
+	d.mutex.Lock()
+	if d.stopCh == nil {
+		d.mutex.Unlock()
+		return fmt.Errorf("damping manager %v not started", d.name)
+	}
+	close(d.stopCh)
+	d.stopCh = nil
+	monitorDone := d.monitorDone
+	d.monitorDone = nil
+	// The monitor needs the lock to check the clients
+	d.mutex.Unlock()
+	<-monitorDone
+	return nil
+}
+
+func (d *damping) monitor(stopCh chan struct{}) {
+	ticker := time.NewTicker(d.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			for _, clientID := range d.reusableClients() {
+				d.dcf(clientID)
+			}
+		}
+	}
+}
+
+// reusableClients returns the suppressed clients whose penalty has decayed
+// below the reuse threshold. It also forgets the clients with no penalty.
+func (d *damping) reusableClients() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	reusable := []string{}
+	for clientID, c := range d.clients {
+		d.decay(c, now)
+		if c.suppressed && c.penalty < d.config.ReuseThreshold {
+			c.suppressed = false
+			reusable = append(reusable, clientID)
+		}
+		if !c.suppressed && c.penalty < minPenalty {
+			delete(d.clients, clientID)
+		}
+	}
+	return reusable
+}
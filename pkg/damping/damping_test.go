@@ -0,0 +1,150 @@
+package damping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testCheckInterval = 100 * time.Millisecond
+)
+
+var testConfig = types.FlapDampingConfig{
+	Penalty:           types.DEFAULT_FLAP_PENALTY,
+	SuppressThreshold: types.DEFAULT_FLAP_SUPPRESS_THRESHOLD,
+	ReuseThreshold:    types.DEFAULT_FLAP_REUSE_THRESHOLD,
+	MaxPenalty:        types.DEFAULT_FLAP_MAX_PENALTY,
+	HalfLife:          time.Minute,
+}
+
+func setup(now *time.Time, callbackFn CallbackFn) *damping {
+	return NewDampingManagerWithClock("test", testConfig, testCheckInterval, callbackFn,
+		func() time.Time {
+			return *now
+		}).(*damping)
+}
+
+func TestDampingSuppress(t *testing.T) {
+	now := time.Now()
+	d := setup(&now, nil)
+
+	require.False(t, d.Flap("client1"), "Expected client not suppressed after one flap")
+	require.False(t, d.IsSuppressed("client1"), "Expected client not suppressed after one flap")
+	require.True(t, d.Flap("client1"), "Expected client suppressed after two flaps")
+	require.True(t, d.IsSuppressed("client1"), "Expected client suppressed after two flaps")
+	require.False(t, d.IsSuppressed("client2"), "Expected unknown client not suppressed")
+}
+
+func TestDampingPenaltyDecay(t *testing.T) {
+	now := time.Now()
+	d := setup(&now, nil)
+
+	d.Flap("client1")
+	require.Equal(t, testConfig.Penalty, d.Penalty("client1"))
+	now = now.Add(testConfig.HalfLife)
+	require.InDelta(t, testConfig.Penalty/2, d.Penalty("client1"), 0.001)
+
+	// Flaps spread across half lives never get suppressed
+	for i := 0; i < 10; i++ {
+		now = now.Add(2 * testConfig.HalfLife)
+		require.False(t, d.Flap("client1"), "Expected client not suppressed")
+	}
+}
+
+func TestDampingMaxPenalty(t *testing.T) {
+	now := time.Now()
+	d := setup(&now, nil)
+
+	for i := 0; i < 10; i++ {
+		d.Flap("client1")
+	}
+	require.Equal(t, testConfig.MaxPenalty, d.Penalty("client1"))
+}
+
+func TestDampingReuse(t *testing.T) {
+	now := time.Now()
+	d := setup(&now, nil)
+
+	d.Flap("client1")
+	d.Flap("client1")
+	require.Empty(t, d.reusableClients())
+
+	// 2000 -> 1000 -> 500 after two half lives
+	now = now.Add(2 * testConfig.HalfLife)
+	require.Equal(t, []string{"client1"}, d.reusableClients())
+	require.False(t, d.IsSuppressed("client1"), "Expected client not suppressed")
+
+	// Client gets forgotten once its penalty decays
+	now = now.Add(20 * testConfig.HalfLife)
+	require.Empty(t, d.reusableClients())
+	require.Equal(t, float64(0), d.Penalty("client1"))
+}
+
+func TestDampingCallback(t *testing.T) {
+	now := time.Now()
+	doneCh := make(chan string, 1)
+	d := setup(&now, func(clientID string) error {
+		doneCh <- clientID
+		return nil
+	})
+	require.NoError(t, d.Start(), "Failed to Start")
+	require.Error(t, d.Start(), "Expected second Start to fail")
+	defer d.Stop()
+
+	d.Flap("client1")
+	d.Flap("client1")
+	d.mutex.Lock()
+	now = now.Add(2 * testConfig.HalfLife)
+	d.mutex.Unlock()
+
+	select {
+	case clientID := <-doneCh:
+		require.Equal(t, "client1", clientID)
+	case <-time.After(10 * testCheckInterval):
+		require.Fail(t, "Callback not invoked for reusable client")
+	}
+}
+
+func TestDampingStopWaitsForCallback(t *testing.T) {
+	now := time.Now()
+	calledCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	d := setup(&now, func(clientID string) error {
+		close(calledCh)
+		<-releaseCh
+		return nil
+	})
+	require.NoError(t, d.Start(), "Failed to Start")
+
+	d.Flap("client1")
+	d.Flap("client1")
+	d.mutex.Lock()
+	now = now.Add(2 * testConfig.HalfLife)
+	d.mutex.Unlock()
+	select {
+	case <-calledCh:
+	case <-time.After(10 * testCheckInterval):
+		require.Fail(t, "Callback not invoked for reusable client")
+	}
+
+	stoppedCh := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stoppedCh)
+	}()
+	select {
+	case <-stoppedCh:
+		require.Fail(t, "Stop returned while the callback is running")
+	case <-time.After(2 * testCheckInterval):
+	}
+	close(releaseCh)
+	select {
+	case <-stoppedCh:
+	case <-time.After(10 * testCheckInterval):
+		require.Fail(t, "Stop did not return after the callback")
+	}
+	require.Error(t, d.Stop(), "Expected second Stop to fail")
+}
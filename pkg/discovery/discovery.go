@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// fileWatcher caches the contents of a file and reloads them whenever the
+// file changes
+type fileWatcher struct {
+	path    string
+	parse   func([]byte) ([]string, error)
+	lock    sync.Mutex
+	modTime time.Time
+	size    int64
+	addrs   []string
+}
+
+func (f *fileWatcher) load() ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size && f.addrs != nil {
+		// file has not changed since we last read it
+		return f.addrs, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := f.parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", f.path, err)
+	}
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	f.addrs = addrs
+	return addrs, nil
+}
+
+type staticFileDiscoverer struct {
+	watcher *fileWatcher
+}
+
+// NewStaticFileDiscoverer returns a Discoverer which reads the seed addresses
+// from a file with one ip:port per line. Empty lines and lines starting with
+// # are ignored. The file is read again whenever it changes.
+func NewStaticFileDiscoverer(path string) types.Discoverer {
+	return &staticFileDiscoverer{
+		watcher: &fileWatcher{
+			path:  path,
+			parse: parseStaticFile,
+		},
+	}
+}
+
+func (s *staticFileDiscoverer) Discover() ([]string, error) {
+	return s.watcher.load()
+}
+
+func (s *staticFileDiscoverer) String() string {
+	return "static file " + s.watcher.path
+}
+
+func parseStaticFile(data []byte) ([]string, error) {
+	addrs := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	return addrs, scanner.Err()
+}
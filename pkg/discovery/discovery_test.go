@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path string, data string, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644), "Failed to write file")
+	require.NoError(t, os.Chtimes(path, modTime, modTime), "Failed to set file times")
+}
+
+func TestStaticFileDiscoverer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "seeds")
+	d := NewStaticFileDiscoverer(path)
+	_, err = d.Discover()
+	require.Error(t, err, "Expected error for missing file")
+
+	now := time.Now()
+	writeTestFile(t, path, "# seeds\n127.0.0.1:9000\n\n 127.0.0.2:9000 \n", now)
+	addrs, err := d.Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"127.0.0.1:9000", "127.0.0.2:9000"}, addrs)
+
+	// File changes are picked up
+	writeTestFile(t, path, "127.0.0.3:9000\n", now.Add(time.Second))
+	addrs, err = d.Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"127.0.0.3:9000"}, addrs)
+}
+
+func TestEndpointsFileDiscoverer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "endpoints.json")
+	writeTestFile(t, path, `{
+		"kind": "Endpoints",
+		"apiVersion": "v1",
+		"metadata": {"name": "gossip"},
+		"subsets": [{
+			"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}],
+			"notReadyAddresses": [{"ip": "fd00::3"}],
+			"ports": [{"name": "rest", "port": 9001}, {"name": "gossip", "port": 9002}]
+		}]
+	}`, time.Now())
+
+	addrs, err := NewEndpointsFileDiscoverer(path, "gossip").Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"10.0.0.1:9002", "10.0.0.2:9002", "[fd00::3]:9002"}, addrs)
+
+	addrs, err = NewEndpointsFileDiscoverer(path, "").Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"10.0.0.1:9001", "10.0.0.2:9001", "[fd00::3]:9001"}, addrs)
+
+	_, err = NewEndpointsFileDiscoverer(path, "unknown").Discover()
+	require.Error(t, err, "Expected error for unknown port")
+}
+
+func TestDNSDiscoverer(t *testing.T) {
+	hosts := map[string][]string{
+		"gossip.local": {"10.0.0.1", "10.0.0.2"},
+		"node1.local":  {"10.0.0.1"},
+		"node2.local":  {"fd00::2"},
+	}
+	lookupHost := func(host string) ([]string, error) {
+		ips, ok := hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("no such host %v", host)
+		}
+		return ips, nil
+	}
+	lookupSRV := func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{
+			{Target: "node1.local.", Port: 9002},
+			{Target: "node2.local.", Port: 9003},
+			{Target: "unknown.local.", Port: 9004},
+		}, nil
+	}
+
+	d := NewDNSDiscoverer("gossip.local", 9002).(*dnsDiscoverer)
+	d.lookupHost = lookupHost
+	addrs, err := d.Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"10.0.0.1:9002", "10.0.0.2:9002"}, addrs)
+
+	d = NewDNSDiscoverer("_gossip._tcp.gossip.local", 0).(*dnsDiscoverer)
+	d.lookupHost = lookupHost
+	d.lookupSRV = lookupSRV
+	addrs, err = d.Discover()
+	require.NoError(t, err, "Failed to discover")
+	require.Equal(t, []string{"10.0.0.1:9002", "[fd00::2]:9003"}, addrs)
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+type dnsDiscoverer struct {
+	name       string
+	port       int
+	lookupHost func(host string) ([]string, error)
+	lookupSRV  func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSDiscoverer returns a Discoverer which looks up the seed addresses
+// in DNS. If a port is provided the A and AAAA records of name are used with
+// that port, otherwise the SRV records of name provide both the targets and
+// their ports.
+func NewDNSDiscoverer(name string, port int) types.Discoverer {
+	return &dnsDiscoverer{
+		name:       name,
+		port:       port,
+		lookupHost: net.LookupHost,
+		lookupSRV:  net.LookupSRV,
+	}
+}
+
+func (d *dnsDiscoverer) Discover() ([]string, error) {
+	if d.port != 0 {
+		ips, err := d.lookupHost(d.name)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(d.port)))
+		}
+		return addrs, nil
+	}
+
+	_, srvs, err := d.lookupSRV("", "", d.name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	for _, srv := range srvs {
+		ips, err := d.lookupHost(strings.TrimSuffix(srv.Target, "."))
+		if err != nil {
+			// Try the other targets
+			continue
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(int(srv.Port))))
+		}
+	}
+	return addrs, nil
+}
+
+func (d *dnsDiscoverer) String() string {
+	if d.port != 0 {
+		return "dns " + net.JoinHostPort(d.name, strconv.Itoa(d.port))
+	}
+	return "dns srv " + d.name
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// endpoints is the subset of a Kubernetes Endpoints object which is needed
+// to find the seed addresses
+type endpoints struct {
+	Subsets []struct {
+		Addresses         []endpointAddress `json:"addresses"`
+		NotReadyAddresses []endpointAddress `json:"notReadyAddresses"`
+		Ports             []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+type endpointAddress struct {
+	IP string `json:"ip"`
+}
+
+type endpointsFileDiscoverer struct {
+	portName string
+	watcher  *fileWatcher
+}
+
+// NewEndpointsFileDiscoverer returns a Discoverer which reads the seed
+// addresses from a JSON file holding a Kubernetes Endpoints object, as
+// produced for a headless service. The port with the given name is used,
+// or the first port if no name is provided. Addresses which are not ready
+// are included as well since nodes need to gossip before they get ready.
+// The file is read again whenever it changes.
+func NewEndpointsFileDiscoverer(path string, portName string) types.Discoverer {
+	e := &endpointsFileDiscoverer{
+		portName: portName,
+	}
+	e.watcher = &fileWatcher{
+		path:  path,
+		parse: e.parse,
+	}
+	return e
+}
+
+func (e *endpointsFileDiscoverer) Discover() ([]string, error) {
+	return e.watcher.load()
+}
+
+func (e *endpointsFileDiscoverer) String() string {
+	return "endpoints file " + e.watcher.path
+}
+
+func (e *endpointsFileDiscoverer) parse(data []byte) ([]string, error) {
+	var ep endpoints
+	if err := json.Unmarshal(data, &ep); err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	for _, subset := range ep.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if e.portName == "" || p.Name == e.portName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			return nil, fmt.Errorf("port %q not found", e.portName)
+		}
+		for _, addresses := range [][]endpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+			for _, address := range addresses {
+				addrs = append(addrs, net.JoinHostPort(address.IP, strconv.Itoa(port)))
+			}
+		}
+	}
+	return addrs, nil
+}
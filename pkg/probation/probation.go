@@ -23,6 +23,9 @@ type Probation interface {
 	// Start starts monitoring the probationList with the configured
 	// probationTimeout
 	Start() error
+	// Stop removes all the clients from the probation list without
+	// invoking the callback for them
+	Stop() error
 }
 
 type probation struct {
@@ -120,3 +123,13 @@ func (p *probation) Start() error {
 	p.schedInst.Start()
 	return nil
 }
+
+func (p *probation) Stop() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for clientID, taskID := range p.probationTasks {
+		p.schedInst.Cancel(taskID) // the task could already be complete
+		delete(p.probationTasks, clientID)
+	}
+	return nil
+}
@@ -111,6 +111,26 @@ func TestProbationRemove(t *testing.T) {
 	require.True(t, os.IsNotExist(err), "Expected callback fn to be executed for client4")
 }
 
+func TestProbationStop(t *testing.T) {
+	p := setup()
+	err := p.Start()
+	require.NoError(t, err, "Failed to Start")
+
+	err = p.Add("client5", nil, true)
+	require.NoError(t, err, "Failed to Add")
+	_, err = os.Create(testFileName("client5"))
+	require.NoError(t, err, "Expected no error on Create")
+
+	err = p.Stop()
+	require.NoError(t, err, "Failed to Stop")
+	require.False(t, p.Exists("client5"), "Expected client to be removed on Stop")
+
+	time.Sleep(testWaitTime)
+
+	_, err = os.Stat(testFileName("client5"))
+	require.NoError(t, err, "Expected callback fn to be not executed for client5")
+}
+
 func testCallback(clientID string, clientData interface{}) error {
 	return os.RemoveAll(testFileName(clientID))
 }
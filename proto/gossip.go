@@ -1,6 +1,7 @@
 package proto
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -43,7 +44,10 @@ type GossiperImpl struct {
 	GossipDelegate
 
 	mlConf *ml.Config
-	mlist  *ml.Memberlist
+	// mlist is replaced under joinLock and mlistLock when the advertise
+	// address changes. Readers which do not hold joinLock use getMemberlist.
+	mlist     *ml.Memberlist
+	mlistLock sync.Mutex
 
 	// node list, maintained separately
 	nodes          GossipNodeList
@@ -52,11 +56,43 @@ type GossiperImpl struct {
 	gossipInterval time.Duration
 	quorumProvider state.Quorum
 	//nodeDeathInterval time.Duration
-	shutDown          bool
+	shutDown bool
+	// running is true from the time gossip is started till it is stopped
+	running           bool
 	selfNodeId        types.NodeId
 	selfClusterDomain string
 	joinLock          sync.Mutex
 	hasJoinedCluster  bool
+	// joinRetryConfig configures the retries of failed joins
+	// and the periodic re-joins
+	joinRetryConfig types.JoinRetryConfig
+	// seedIps are the ips of the known nodes provided at Start
+	seedIps      []string
+	rejoinStopCh chan struct{}
+	// discoverer provides additional seed nodes
+	discoverer        types.Discoverer
+	discoveryInterval time.Duration
+	// resolver resolves the DNS names in peer addresses
+	resolver        *addrResolver
+	resolveInterval time.Duration
+	resolveStopCh   chan struct{}
+	// onDuplicateNodeId is invoked when a duplicate node id is detected
+	onDuplicateNodeId     func(err *types.DuplicateNodeIdError)
+	refuseDuplicateNodeId bool
+	conflictChecks        pendingTasks
+	duplicateNodeIdErr    *types.DuplicateNodeIdError
+	// conflictsSeen tracks the conflicting addresses already handled
+	conflictsSeen       map[string]bool
+	duplicateNodeIdLock sync.Mutex
+	// clusterIdMigrationTimer fires when the window of a cluster id
+	// migration closes
+	clusterIdMigrationTimer *time.Timer
+	// federation is the gossip pool of the cluster gateways. It is nil
+	// if this node is not a gateway.
+	federation *federation
+	// domainActivation applies the active maps proposed by the domain
+	// activation policy
+	domainActivation domainActivation
 }
 
 // Utility methods
@@ -113,10 +149,12 @@ func (g *GossiperImpl) Init(
 		selfClusterDomain,
 		g.Ping,
 	)
+	g.quorumRecoveryInterval = gossipIntervals.QuorumRecoveryInterval
 	mlConf.Delegate = ml.Delegate(g)
 	mlConf.Events = ml.EventDelegate(g)
 	mlConf.Alive = ml.AliveDelegate(g)
 	mlConf.Merge = ml.MergeDelegate(g)
+	mlConf.Conflict = ml.ConflictDelegate(g)
 	filter := &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
 		MinLevel: logutils.LogLevel("INFO"),
@@ -125,15 +163,70 @@ func (g *GossiperImpl) Init(
 	mlConf.LogOutput = filter
 
 	g.mlConf = mlConf
+	g.resolver = newAddrResolver()
+	g.peerDown = g.resolvePeer
 	g.selfNodeId = selfNodeId
 	g.selfClusterDomain = selfClusterDomain
 	rand.Seed(time.Now().UnixNano())
 }
 
 func (g *GossiperImpl) Start(config types.GossipStartConfiguration) error {
-	g.quorumProvider = state.NewQuorumProvider(g.selfNodeId, config.QuorumProviderType)
+	return g.StartWithContext(context.Background(), config)
+}
+
+func (g *GossiperImpl) StartWithContext(ctx context.Context, config types.GossipStartConfiguration) error {
+	g.joinLock.Lock()
+	if g.running {
+		g.joinLock.Unlock()
+		return fmt.Errorf("gossip: Gossiper already started")
+	}
+	g.running = true
+	restart := g.shutDown
+	g.shutDown = false
+	g.joinLock.Unlock()
+
+	if restart {
+		g.resetNodeStatuses()
+		g.duplicateNodeIdLock.Lock()
+		g.duplicateNodeIdErr = nil
+		g.conflictsSeen = nil
+		g.duplicateNodeIdLock.Unlock()
+	}
+	if err := g.start(ctx, config); err != nil {
+		// Clean up whatever was started
+		g.stop(context.Background(), 0, "")
+		return err
+	}
+	return nil
+}
+
+func (g *GossiperImpl) start(ctx context.Context, config types.GossipStartConfiguration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	quorumProvider, err := state.GetQuorumProvider(g.selfNodeId, config)
+	if err != nil {
+		return err
+	}
+	g.quorumProvider = quorumProvider
+	if err := g.initStateHistory(config.StateHistory); err != nil {
+		return err
+	}
 
 	g.InitCurrentState(uint(len(config.Nodes)+1), g.quorumProvider)
+	if g.quorumProvider.Type() == types.QUORUM_PROVIDER_ARBITER {
+		g.startArbiterRenewal(config.ArbiterLeaseTTL)
+	}
+	g.startFencer(config.OnFenceHookTimeout)
+	g.startDomainActivation(config.DomainActivation)
+	if err := g.InitFlapDamping(config.FlapDamping); err != nil {
+		return err
+	}
+	g.joinRetryConfig = joinRetryConfigWithDefaults(config.JoinRetry)
+	if err := validateGossipVersionRange(g.GetGossipVersion(), config.MinGossipVersion, config.MaxGossipVersion); err != nil {
+		return err
+	}
+	g.setGossipVersionRange(config.MinGossipVersion, config.MaxGossipVersion)
 
 	// Populate the list of known ips
 	knownIps := []string{}
@@ -145,23 +238,65 @@ func (g *GossiperImpl) Start(config types.GossipStartConfiguration) error {
 			g.updateClusterDomainsMap(nodeConfig.ClusterDomain, nodeId)
 		}
 	}
+	if err := g.initAdvertiseAddr(config.AdvertiseAddr); err != nil {
+		return err
+	}
+	g.resolver.preferIPv6 = isIPv6Addr(g.getAdvertiseAddr())
+	g.resolveInterval = config.ResolveInterval
+	if g.resolveInterval == 0 {
+		g.resolveInterval = types.DEFAULT_RESOLVE_INTERVAL
+	}
+	g.onDuplicateNodeId = config.OnDuplicateNodeId
+	g.refuseDuplicateNodeId = config.RefuseDuplicateNodeId
+	g.onClusterIdMigrated = config.OnClusterIdMigrated
+	if err := g.checkLabelsSize(config.Labels); err != nil {
+		return err
+	}
+	g.updateSelfLabels(config.Labels)
+
+	g.seedIps = knownIps
+	g.discoverer = config.Discoverer
+	g.discoveryInterval = config.DiscoveryInterval
+	if g.discoveryInterval == 0 {
+		g.discoveryInterval = types.DEFAULT_DISCOVERY_INTERVAL
+	}
+	knownIps = appendAddrs(knownIps, g.discoverSeeds())
 	// Update the activation map so that the subsequent IsDomainActive call returns
 	// the current status
 	g.quorumProvider.UpdateClusterDomainsActiveMap(config.ActiveMap)
 
 	g.joinLock.Lock()
-	defer g.joinLock.Unlock()
+	if config.Federation != nil {
+		if err := g.startFederation(config.Federation); err != nil {
+			g.joinLock.Unlock()
+			return err
+		}
+	}
 	if g.quorumProvider.IsDomainActive(g.selfClusterDomain) {
 		// Only start gossiping/join if the node is active and we have a list of
 		// peer node ips
 		if err := g.startMemberlist(knownIps); err != nil {
+			g.stopFederation(0)
+			g.joinLock.Unlock()
 			return err
 		}
 		g.hasJoinedCluster = true
 	} else {
 		log.Infof("gossip: Not gossiping with other nodes as our domain %v is marked inactive", g.selfClusterDomain)
 	}
-	return nil
+	g.joinLock.Unlock()
+
+	// Wait for the duplicate node id checks triggered by the join. They
+	// need the joinLock to stop gossiping.
+	select {
+	case <-g.conflictChecks.wait():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := g.getDuplicateNodeIdErr(); err != nil {
+		return err
+	}
+	return ctx.Err()
 }
 
 func (g *GossiperImpl) startMemberlist(knownIps []string) error {
@@ -173,62 +308,184 @@ func (g *GossiperImpl) startMemberlist(knownIps []string) error {
 		return err
 	}
 	// Set the memberlist in gossiper object
-	g.mlist = list
+	g.setMemberlist(list)
+	joined := true
 	if len(knownIps) > 0 {
 		joinedNodes, err := list.Join(knownIps)
 		if err != nil {
 			log.Infof("gossip: Unable to join other nodes at startup : %v", err)
-			return err
+			if !g.joinRetryEnabled() {
+				list.Shutdown()
+				return err
+			}
+			// The rejoiner will keep retrying in the background
+			joined = false
+		} else {
+			log.Infof("gossip: Successfully joined with %v node(s)", joinedNodes)
 		}
-		log.Infof("gossip: Successfully joined with %v node(s)", joinedNodes)
 	}
+	g.startRejoiner(joined)
+	g.startResolver()
 	return nil
 }
 
+// setMemberlist replaces the memberlist. joinLock must be held.
+func (g *GossiperImpl) setMemberlist(list *ml.Memberlist) {
+	g.mlistLock.Lock()
+	defer g.mlistLock.Unlock()
+	g.mlist = list
+}
+
+// getMemberlist returns the current memberlist to the callers which do not
+// hold joinLock
+func (g *GossiperImpl) getMemberlist() *ml.Memberlist {
+	g.mlistLock.Lock()
+	defer g.mlistLock.Unlock()
+	return g.mlist
+}
+
 func (g *GossiperImpl) Stop(leaveTimeout time.Duration) error {
-	if g.shutDown == true {
+	return g.StopWithReason(leaveTimeout, "")
+}
+
+func (g *GossiperImpl) StopWithReason(leaveTimeout time.Duration, reason string) error {
+	return g.StopWithContext(context.Background(), leaveTimeout, reason)
+}
+
+func (g *GossiperImpl) StopWithContext(ctx context.Context, leaveTimeout time.Duration, reason string) error {
+	g.joinLock.Lock()
+	running, shutDown := g.running, g.shutDown
+	g.joinLock.Unlock()
+	if !running {
+		return fmt.Errorf("gossip: Gossiper already stopped")
+	}
+	err := g.stop(ctx, leaveTimeout, reason)
+	if err == nil && shutDown {
+		// Gossip was stopped because of a duplicate node id
 		return fmt.Errorf("gossip: Gossiper already stopped")
 	}
+	return err
+}
+
+// stop stops gossiping with the other nodes and waits for all the background
+// go routines to exit. If leaveTimeout is specified the node leaves the
+// cluster gracefully.
+func (g *GossiperImpl) stop(ctx context.Context, leaveTimeout time.Duration, reason string) error {
+	var err error
+	g.joinLock.Lock()
+	g.stopRejoiner()
+	g.stopResolver()
+	g.stopClusterIdMigrationTimer()
+	g.stopFederation(leaveTimeout)
+	if g.hasJoinedCluster && !g.shutDown {
+		err = g.shutdownMemberlist(leaveTimeout, reason)
+	}
+	g.hasJoinedCluster = false
+	g.shutDown = true
+	g.running = false
+	g.joinLock.Unlock()
+
+	g.stopStateHandler()
+	select {
+	case <-allDone(waitGroupDone(&g.routines), g.conflictChecks.wait()):
+	case <-ctx.Done():
+		log.Warnf("gossip: Background tasks did not stop: %v", ctx.Err())
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	g.closeStateHistory()
+	return err
+}
+
+func (g *GossiperImpl) shutdownMemberlist(leaveTimeout time.Duration, reason string) error {
+	var leaveErr error
 	// If leaveTimeout is specified then gracefully shutdown
 	if leaveTimeout != time.Duration(0) {
-		if err := g.mlist.Leave(leaveTimeout); err != nil {
-			return err
+		// Let the other nodes know that we are leaving on our own
+		g.updateNodeLeaving(g.selfNodeId, true, reason)
+		if err := g.mlist.UpdateNode(leaveTimeout); err != nil {
+			log.Warnf("gossip: Unable to broadcast leave reason: %v", err)
 		}
+		leaveErr = g.mlist.Leave(leaveTimeout)
 	}
 	if err := g.mlist.Shutdown(); err != nil {
 		return err
 	}
-	g.shutDown = true
-	return nil
+	return leaveErr
+}
+
+// waitGroupDone returns a channel which is closed once the wait group is done
+func waitGroupDone(wg *sync.WaitGroup) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// allDone returns a channel which is closed once all the channels are closed
+func allDone(chs ...<-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for _, ch := range chs {
+			<-ch
+		}
+		close(done)
+	}()
+	return done
 }
 
 func (g *GossiperImpl) Ping(peerNode types.NodeId, addr string) (time.Duration, error) {
+	if negotiated, ok := g.peerNegotiatedVersion(peerNode); ok {
+		// Ping needs both the nodes to talk gossip version v2 or higher
+		if v, err := parseGossipVersion(negotiated); err == nil && v < 2 {
+			return 0, fmt.Errorf("gossip: Ping is not supported with gossip version %v "+
+				"negotiated with node %v", negotiated, peerNode)
+		}
+	}
+	resolvedAddr, _, err := g.resolver.resolve(addr, false)
+	if err != nil {
+		return 0, err
+	}
+	pingDuration, pingErr := g.pingAddr(peerNode, resolvedAddr)
+	if pingErr == nil || !isHostnameAddr(addr) {
+		return pingDuration, pingErr
+	}
+
+	// The node could have moved to a new ip
+	resolvedAddr, changed, err := g.resolver.resolve(addr, true)
+	if err != nil || !changed {
+		return pingDuration, pingErr
+	}
+	log.Infof("gossip: Address %v of node %v now resolves to %v", addr, peerNode, resolvedAddr)
+	return g.pingAddr(peerNode, resolvedAddr)
+}
+
+func (g *GossiperImpl) pingAddr(peerNode types.NodeId, addr string) (time.Duration, error) {
 	var (
 		pingErr      error
 		pingDuration time.Duration
 	)
 
-	addrHost, addrPort, err := net.SplitHostPort(addr)
+	netAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return pingDuration, err
 	}
-	ipPort := []string{addrHost, addrPort}
-
-	port, err := strconv.ParseInt(ipPort[1], 10, 64)
-	if err != nil {
-		return pingDuration, err
-	}
-
-	netAddr := &net.UDPAddr{net.ParseIP(ipPort[0]), int(port), ""}
 
 	pingRetries := 3
 
-	memberlistNodeName := string(peerNode) + types.GOSSIP_VERSION_2
+	peerVersion, ok := g.peerGossipVersion(peerNode)
+	if !ok {
+		peerVersion = types.GOSSIP_VERSION_2
+	}
+	memberlistNodeName := string(peerNode) + peerVersion
 
 	// Ping the node and return success when you get a ping response.
 	// Retry at most 3 times on failure
 	for i := 0; i < pingRetries; i++ {
-		pingDuration, pingErr = g.mlist.Ping(memberlistNodeName, netAddr)
+		pingDuration, pingErr = g.getMemberlist().Ping(memberlistNodeName, netAddr)
 		if pingErr == nil {
 			return pingDuration, nil
 		}
@@ -242,7 +499,7 @@ func (g *GossiperImpl) GossipInterval() time.Duration {
 }
 
 func (g *GossiperImpl) GetNodes() []string {
-	nodes := g.mlist.Members()
+	nodes := g.getMemberlist().Members()
 	nodeList := make([]string, len(nodes))
 	for i, node := range nodes {
 		nodeList[i] = node.Addr.String()
@@ -262,7 +519,10 @@ func (g *GossiperImpl) UpdateCluster(peers map[types.NodeId]types.NodeUpdate) {
 
 func (g *GossiperImpl) ExternalNodeLeave(nodeId types.NodeId) types.NodeId {
 	log.Infof("gossip: Request for a Node Leave operation on Node %v", nodeId)
-	if g.GetSelfStatus() == types.NODE_STATUS_UP && g.quorumProvider.IsDomainActive(g.selfClusterDomain) {
+	selfStatus := g.GetSelfStatus()
+	// Witnesses are in quorum irrespective of the activation of their domain
+	if (selfStatus == types.NODE_STATUS_UP && g.quorumProvider.IsDomainActive(g.selfClusterDomain)) ||
+		selfStatus == types.NODE_STATUS_WITNESS {
 		log.Infof("gossip: Node %v should go down.", nodeId)
 		return nodeId
 	} else {
@@ -273,17 +533,30 @@ func (g *GossiperImpl) ExternalNodeLeave(nodeId types.NodeId) types.NodeId {
 	}
 }
 
+func (g *GossiperImpl) ExplainQuorum() types.QuorumExplanation {
+	return g.explainQuorum(g.GetLocalState())
+}
+
 func (g *GossiperImpl) UpdateClusterDomainsActiveMap(activeMap types.ClusterDomainsActiveMap) error {
 	if g.quorumProvider == nil {
 		return fmt.Errorf("gossip: not started yet")
 	}
+	g.domainActivation.override(time.Now())
+	return g.updateClusterDomainsActiveMap(activeMap)
+}
+
+func (g *GossiperImpl) updateClusterDomainsActiveMap(activeMap types.ClusterDomainsActiveMap) error {
 	stateChanged := g.quorumProvider.UpdateClusterDomainsActiveMap(activeMap)
 	if stateChanged {
+		if !g.quorumProvider.IsDomainActive(g.selfClusterDomain) {
+			// Fence right away instead of waiting for the quorum timeout
+			g.fencer.request(types.FENCE_ACTION_FENCE, g.GetSelfStatus())
+		}
 		g.triggerStateEvent(types.UPDATE_CLUSTER_DOMAINS_ACTIVE_MAP)
 
 		g.joinLock.Lock()
 		defer g.joinLock.Unlock()
-		if g.quorumProvider.IsDomainActive(g.selfClusterDomain) && !g.hasJoinedCluster {
+		if g.running && g.quorumProvider.IsDomainActive(g.selfClusterDomain) && !g.hasJoinedCluster {
 			// State changed to active and this node has not joined the cluster yet.
 			// Start gossiping to the nodes which we know. GetLocalState will return
 			// the latest set of nodes and their IPs. This list of nodes is updated
@@ -291,7 +564,7 @@ func (g *GossiperImpl) UpdateClusterDomainsActiveMap(activeMap types.ClusterDoma
 			allNodes := g.GetLocalState()
 			knownIps := []string{}
 			for _, nodeInfo := range allNodes {
-				knownIps = append(knownIps, nodeInfo.Addr)
+				knownIps = append(knownIps, g.peerJoinAddr(nodeInfo))
 			}
 			if err := g.startMemberlist(knownIps); err != nil {
 				return err
@@ -309,3 +582,84 @@ func (g *GossiperImpl) UpdateSelfClusterDomain(selfClusterDomain string) {
 		g.triggerStateEvent(types.SELF_ALIVE)
 	}
 }
+
+func (g *GossiperImpl) EnterMaintenance() error {
+	if g.quorumProvider == nil {
+		return fmt.Errorf("gossip: not started yet")
+	}
+	if g.updateSelfMaintenance(true) {
+		log.Infof("gossip: Entering maintenance")
+		g.triggerStateEvent(types.ENTER_MAINTENANCE)
+		g.broadcastMaintenance()
+	}
+	return nil
+}
+
+func (g *GossiperImpl) ExitMaintenance() error {
+	if g.quorumProvider == nil {
+		return fmt.Errorf("gossip: not started yet")
+	}
+	if g.updateSelfMaintenance(false) {
+		log.Infof("gossip: Exiting maintenance")
+		g.triggerStateEvent(types.EXIT_MAINTENANCE)
+		g.broadcastMaintenance()
+	}
+	return nil
+}
+
+// broadcastMaintenance announces our maintenance mode in our node meta data
+// so that the other nodes see it even if we stop gossiping right after
+func (g *GossiperImpl) broadcastMaintenance() {
+	g.joinLock.Lock()
+	defer g.joinLock.Unlock()
+	if !g.hasJoinedCluster || g.shutDown {
+		// Maintenance mode is published once we start gossiping
+		return
+	}
+	if err := g.mlist.UpdateNode(metaBroadcastTimeout); err != nil {
+		// The other nodes will still get the maintenance mode on the next push/pull
+		log.Warnf("gossip: Unable to broadcast maintenance mode: %v", err)
+	}
+}
+
+// metaBroadcastTimeout is the time for which we wait for updated node meta
+// data to be broadcasted to the other nodes
+const metaBroadcastTimeout = 10 * time.Second
+
+func (g *GossiperImpl) UpdateSelfLabels(labels map[string]string) error {
+	if err := g.checkLabelsSize(labels); err != nil {
+		return err
+	}
+	g.updateSelfLabels(labels)
+
+	g.joinLock.Lock()
+	defer g.joinLock.Unlock()
+	if !g.hasJoinedCluster || g.shutDown {
+		// Labels are published once we start gossiping
+		return nil
+	}
+	if err := g.mlist.UpdateNode(metaBroadcastTimeout); err != nil {
+		// The other nodes will still get the labels on the next push/pull
+		log.Warnf("gossip: Unable to broadcast updated labels: %v", err)
+	}
+	return nil
+}
+
+// checkLabelsSize returns an error if the node meta data with the given
+// labels does not fit in the memberlist meta data limit
+func (g *GossiperImpl) checkLabelsSize(labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	nodeMeta := g.MetaInfo()
+	nodeMeta.Labels = labels
+	metaBytes, err := g.convertToBytes(nodeMeta)
+	if err != nil {
+		return err
+	}
+	if len(metaBytes) > ml.MetaMaxSize {
+		return fmt.Errorf("gossip: labels too large, node meta data size %v exceeds the limit %v",
+			len(metaBytes), ml.MetaMaxSize)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package proto
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// domainActivation applies the active maps proposed by the domain
+// activation policy. It guards against the active map flip-flopping by
+// applying a change only if it is proposed for the stable interval and
+// not earlier than the hold down after the previous change.
+type domainActivation struct {
+	lock   sync.Mutex
+	config types.DomainActivationConfig
+	// overridden is true if the active map was updated manually
+	overridden bool
+	// candidate is the active map being proposed since candidateSince
+	candidate      types.ClusterDomainsActiveMap
+	candidateSince time.Time
+	lastChange     time.Time
+}
+
+func (a *domainActivation) init(config types.DomainActivationConfig) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if config.Interval == 0 {
+		config.Interval = types.DEFAULT_DOMAIN_ACTIVATION_INTERVAL
+	}
+	if config.StableInterval == 0 {
+		config.StableInterval = types.DEFAULT_DOMAIN_ACTIVATION_STABLE_INTERVAL
+	}
+	if config.HoldDown == 0 {
+		config.HoldDown = types.DEFAULT_DOMAIN_ACTIVATION_HOLD_DOWN
+	}
+	a.config = config
+	a.overridden = false
+	a.candidate = nil
+}
+
+func (a *domainActivation) getConfig() types.DomainActivationConfig {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.config
+}
+
+// override stops the policy from changing the active map till it is resumed
+func (a *domainActivation) override(now time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.config.Policy != nil && !a.overridden {
+		log.Infof("gossip: Domain activation policy %v is overridden", a.config.Policy)
+	}
+	a.overridden = true
+	a.candidate = nil
+	a.lastChange = now
+}
+
+func (a *domainActivation) resume() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.config.Policy == nil {
+		return fmt.Errorf("gossip: No domain activation policy is configured")
+	}
+	if a.overridden {
+		log.Infof("gossip: Resuming domain activation policy %v", a.config.Policy)
+	}
+	a.overridden = false
+	return nil
+}
+
+// propose returns the active map to apply for the map proposed by the
+// policy, and false if the current active map should be kept
+func (a *domainActivation) propose(
+	now time.Time,
+	current types.ClusterDomainsActiveMap,
+	proposed types.ClusterDomainsActiveMap,
+) (types.ClusterDomainsActiveMap, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.overridden || !activeMapChanged(current, proposed) {
+		a.candidate = nil
+		return nil, false
+	}
+	activeMap := make(types.ClusterDomainsActiveMap)
+	for domain, domainState := range current {
+		activeMap[domain] = domainState
+	}
+	numActive := 0
+	for domain, domainState := range proposed {
+		activeMap[domain] = domainState
+	}
+	for _, domainState := range activeMap {
+		if domainState == types.CLUSTER_DOMAIN_STATE_ACTIVE {
+			numActive++
+		}
+	}
+	if numActive == 0 {
+		log.Warnf("gossip: Ignoring active map %v proposed by domain activation policy %v "+
+			"as it deactivates all the domains", proposed, a.config.Policy)
+		a.candidate = nil
+		return nil, false
+	}
+	if a.candidate == nil || activeMapChanged(a.candidate, activeMap) {
+		log.Infof("gossip: Domain activation policy %v proposes active map %v", a.config.Policy, activeMap)
+		a.candidate = activeMap
+		a.candidateSince = now
+	}
+	if now.Sub(a.candidateSince) < a.config.StableInterval ||
+		(!a.lastChange.IsZero() && now.Sub(a.lastChange) < a.config.HoldDown) {
+		return nil, false
+	}
+	a.candidate = nil
+	a.lastChange = now
+	return activeMap, true
+}
+
+// activeMapChanged returns true if the proposed map changes the state of
+// any domain in the current map
+func activeMapChanged(current, proposed types.ClusterDomainsActiveMap) bool {
+	for domain, domainState := range proposed {
+		if current[domain] != domainState {
+			return true
+		}
+	}
+	return false
+}
+
+// isAliveForActivation returns true if a node with the given status is
+// alive. Nodes suspected down are treated as alive, so that their domain is
+// not deactivated before they are declared down.
+func isAliveForActivation(status types.NodeStatus) bool {
+	switch status {
+	case types.NODE_STATUS_UP, types.NODE_STATUS_WITNESS,
+		types.NODE_STATUS_NOT_IN_QUORUM, types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM,
+		types.NODE_STATUS_SUSPECT_DOWN:
+		return true
+	}
+	return false
+}
+
+// clusterDomainsHealth returns the health of the cluster domains as per
+// the given state of the cluster
+func (g *GossiperImpl) clusterDomainsHealth(localNodeInfoMap types.NodeInfoMap) map[string]types.DomainHealth {
+	health := make(map[string]types.DomainHealth)
+	for id, nodeInfo := range localNodeInfoMap {
+		domainHealth := health[nodeInfo.ClusterDomain]
+		domainHealth.Active = g.quorumProvider.IsDomainActive(nodeInfo.ClusterDomain)
+		if nodeInfo.QuorumMember && !nodeInfo.Maintenance {
+			alive := id == g.selfNodeId || isAliveForActivation(nodeInfo.Status)
+			domainHealth.QuorumMembers++
+			if alive {
+				domainHealth.AliveMembers++
+			}
+			if nodeInfo.Witness {
+				domainHealth.Witnesses++
+				if alive {
+					domainHealth.AliveWitnesses++
+				}
+			}
+		}
+		health[nodeInfo.ClusterDomain] = domainHealth
+	}
+	return health
+}
+
+// evaluateDomainActivation evaluates the domain activation policy and
+// applies the active map it proposes
+func (g *GossiperImpl) evaluateDomainActivation(policy types.DomainActivationPolicy) {
+	g.joinLock.Lock()
+	observing := g.running && g.hasJoinedCluster
+	g.joinLock.Unlock()
+	if !observing {
+		// Nodes which are not gossiping cannot see the health of the domains
+		return
+	}
+	health := g.clusterDomainsHealth(g.GetLocalState())
+	current := make(types.ClusterDomainsActiveMap)
+	for domain, domainHealth := range health {
+		if domainHealth.Active {
+			current[domain] = types.CLUSTER_DOMAIN_STATE_ACTIVE
+		} else {
+			current[domain] = types.CLUSTER_DOMAIN_STATE_INACTIVE
+		}
+	}
+	activeMap, ok := g.domainActivation.propose(time.Now(), current, policy.ActiveMap(health))
+	if !ok {
+		return
+	}
+	log.Infof("gossip: Domain activation policy %v updates active map to %v", policy, activeMap)
+	if err := g.updateClusterDomainsActiveMap(activeMap); err != nil {
+		log.Warnf("gossip: Unable to update active map: %v", err)
+	}
+}
+
+// startDomainActivation periodically evaluates the domain activation policy
+func (g *GossiperImpl) startDomainActivation(config types.DomainActivationConfig) {
+	g.domainActivation.init(config)
+	if config.Policy == nil {
+		return
+	}
+	interval := g.domainActivation.getConfig().Interval
+	stopCh := g.getStateStopCh()
+	g.routines.Add(1)
+	go func() {
+		defer g.routines.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.evaluateDomainActivation(config.Policy)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (g *GossiperImpl) ResumeDomainActivationPolicy() error {
+	return g.domainActivation.resume()
+}
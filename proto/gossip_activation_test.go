@@ -0,0 +1,106 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/pkg/activation"
+	"github.com/libopenstorage/gossip/proto/state"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainActivationGuards(t *testing.T) {
+	printTestInfo()
+
+	a := &domainActivation{}
+	a.init(types.DomainActivationConfig{
+		Policy:         activation.NewPreferredDomainPolicy("zone1"),
+		StableInterval: 10 * time.Second,
+		HoldDown:       time.Minute,
+	})
+	current := types.ClusterDomainsActiveMap{
+		"zone1": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		"zone2": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+	}
+	deactivate := types.ClusterDomainsActiveMap{"zone2": types.CLUSTER_DOMAIN_STATE_INACTIVE}
+	now := time.Now()
+
+	// A change is applied once it is proposed for the stable interval
+	_, ok := a.propose(now, current, deactivate)
+	require.False(t, ok, "Expected change to wait for the stable interval")
+	_, ok = a.propose(now.Add(5*time.Second), current, current)
+	require.False(t, ok, "Expected no change")
+	_, ok = a.propose(now.Add(10*time.Second), current, deactivate)
+	require.False(t, ok, "Expected stable interval to restart")
+	activeMap, ok := a.propose(now.Add(20*time.Second), current, deactivate)
+	require.True(t, ok, "Expected change to be applied")
+	require.Equal(t, types.ClusterDomainsActiveMap{
+		"zone1": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		"zone2": types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	}, activeMap)
+	current = activeMap
+
+	// The next change waits for the hold down
+	now = now.Add(20 * time.Second)
+	activate := types.ClusterDomainsActiveMap{"zone2": types.CLUSTER_DOMAIN_STATE_ACTIVE}
+	_, ok = a.propose(now.Add(10*time.Second), current, activate)
+	require.False(t, ok, "Expected change to wait for the stable interval")
+	_, ok = a.propose(now.Add(30*time.Second), current, activate)
+	require.False(t, ok, "Expected change to wait for the hold down")
+	_, ok = a.propose(now.Add(time.Minute), current, activate)
+	require.True(t, ok, "Expected change to be applied after the hold down")
+	current["zone2"] = types.CLUSTER_DOMAIN_STATE_ACTIVE
+
+	// All the domains are never deactivated
+	now = now.Add(time.Hour)
+	deactivateAll := types.ClusterDomainsActiveMap{
+		"zone1": types.CLUSTER_DOMAIN_STATE_INACTIVE,
+		"zone2": types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	}
+	_, ok = a.propose(now, current, deactivateAll)
+	require.False(t, ok, "Expected change to be ignored")
+	_, ok = a.propose(now.Add(time.Minute), current, deactivateAll)
+	require.False(t, ok, "Expected change to be ignored")
+
+	// The policy does not change the active map while it is overridden
+	a.override(now)
+	now = now.Add(time.Hour)
+	_, ok = a.propose(now, current, deactivate)
+	require.False(t, ok, "Expected policy to be overridden")
+	_, ok = a.propose(now.Add(time.Minute), current, deactivate)
+	require.False(t, ok, "Expected policy to be overridden")
+	require.NoError(t, a.resume(), "Failed to resume policy")
+	_, ok = a.propose(now.Add(2*time.Minute), current, deactivate)
+	require.False(t, ok, "Expected change to wait for the stable interval")
+	_, ok = a.propose(now.Add(3*time.Minute), current, deactivate)
+	require.True(t, ok, "Expected change to be applied after resuming")
+
+	// A policy can only be resumed if it is configured
+	a.init(types.DomainActivationConfig{})
+	require.Error(t, a.resume(), "Expected an error without a policy")
+}
+
+func TestClusterDomainsHealth(t *testing.T) {
+	printTestInfo()
+
+	g := &GossiperImpl{selfNodeId: "0"}
+	g.quorumProvider = state.NewQuorumProvider("0", types.QUORUM_PROVIDER_FAILURE_DOMAINS)
+	g.quorumProvider.UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap{
+		"zone1": types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		"zone2": types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	})
+	health := g.clusterDomainsHealth(types.NodeInfoMap{
+		"0": {ClusterDomain: "zone1", QuorumMember: true, Status: types.NODE_STATUS_NOT_IN_QUORUM},
+		"1": {ClusterDomain: "zone1", QuorumMember: true, Status: types.NODE_STATUS_DOWN},
+		"2": {ClusterDomain: "zone1", QuorumMember: true, Status: types.NODE_STATUS_UP, Maintenance: true},
+		"3": {ClusterDomain: "zone2", QuorumMember: true, Status: types.NODE_STATUS_SUSPECT_DOWN},
+		"4": {ClusterDomain: "zone2", QuorumMember: false, Status: types.NODE_STATUS_UP},
+		"5": {ClusterDomain: "zone3", QuorumMember: true, Witness: true, Status: types.NODE_STATUS_WITNESS},
+	})
+	require.Equal(t, map[string]types.DomainHealth{
+		"zone1": {Active: true, QuorumMembers: 2, AliveMembers: 1},
+		"zone2": {Active: false, QuorumMembers: 1, AliveMembers: 1},
+		"zone3": {Active: false, QuorumMembers: 1, AliveMembers: 1, Witnesses: 1, AliveWitnesses: 1},
+	}, health)
+}
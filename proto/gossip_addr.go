@@ -0,0 +1,225 @@
+package proto
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// routeProbeAddrV4 and routeProbeAddrV6 are used to find the interface which
+// routes to other hosts. No packets are sent to these addresses.
+var (
+	routeProbeAddrV4 = "192.0.2.1:9"
+	routeProbeAddrV6 = "[2001:db8::1]:9"
+)
+
+func (g *GossiperImpl) GetAdvertiseAddr() string {
+	return g.getAdvertiseAddr()
+}
+
+func (g *GossiperImpl) UpdateAdvertiseAddr(addr string) error {
+	ip, port, err := parseAdvertiseAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	g.joinLock.Lock()
+	defer g.joinLock.Unlock()
+	oldIp, oldPort := g.mlConf.AdvertiseAddr, g.mlConf.AdvertisePort
+	oldAddr := g.getAdvertiseAddr()
+	// Memberlist picks up the new address when it is created
+	g.mlConf.AdvertiseAddr = ip.String()
+	g.mlConf.AdvertisePort = port
+	if !g.updateSelfAdvertiseAddr(addr) {
+		return nil
+	}
+	log.Infof("gossip: Advertising new address %v", addr)
+	if !g.hasJoinedCluster || g.shutDown {
+		return nil
+	}
+
+	// Let the other nodes know about the new address
+	if err := g.mlist.UpdateNode(metaBroadcastTimeout); err != nil {
+		// The other nodes will still get the address on the next push/pull
+		log.Warnf("gossip: Unable to broadcast advertise address: %v", err)
+	}
+	localNode := g.mlist.LocalNode()
+	if localNode.Addr.Equal(ip) && int(localNode.Port) == port {
+		// Memberlist already uses this address
+		return nil
+	}
+
+	// Memberlist on the other nodes refuses a new address for a node it
+	// knows, so memberlist is restarted. The other nodes accept the new
+	// address once they have forgotten the old one.
+	oldBindIp, oldBindPort := g.mlConf.BindAddr, g.mlConf.BindPort
+	if bindIp := net.ParseIP(g.mlConf.BindAddr); bindIp != nil && bindIp.String() == oldIp {
+		g.mlConf.BindAddr = ip.String()
+	}
+	if g.mlConf.BindPort == oldPort {
+		g.mlConf.BindPort = port
+	}
+	if err := g.restartMemberlist(); err != nil {
+		log.Warnf("gossip: Unable to gossip at address %v: %v. Reverting to %v", addr, err, oldAddr)
+		g.mlConf.AdvertiseAddr, g.mlConf.AdvertisePort = oldIp, oldPort
+		g.mlConf.BindAddr, g.mlConf.BindPort = oldBindIp, oldBindPort
+		g.updateSelfAdvertiseAddr(oldAddr)
+		if restartErr := g.restartMemberlist(); restartErr != nil {
+			log.Errorf("gossip: Unable to gossip at address %v: %v. Stopping gossip.", oldAddr, restartErr)
+			g.shutDown = true
+			g.triggerStateEvent(types.SELF_LEAVE)
+		}
+		return fmt.Errorf("gossip: Unable to change advertise address to %v: %v", addr, err)
+	}
+	return nil
+}
+
+// addrChangeLeaveTimeout is the time for which we wait for the other nodes
+// to learn that we left the old address
+const addrChangeLeaveTimeout = 5 * time.Second
+
+// restartMemberlist leaves the cluster and joins it again with the current
+// memberlist configuration. joinLock must be held.
+func (g *GossiperImpl) restartMemberlist() error {
+	addrs := []string{}
+	for _, member := range g.mlist.Members() {
+		if member.Name != g.mlConf.Name {
+			addrs = append(addrs, net.JoinHostPort(member.Addr.String(), strconv.Itoa(int(member.Port))))
+		}
+	}
+	if len(addrs) == 0 {
+		// Nobody knows the old address. Keep trying the seeds.
+		addrs = g.seedIps
+	}
+	g.stopRejoiner()
+	g.stopResolver()
+	if !g.shutDown {
+		// Only the other nodes see us leave
+		g.setRestartingMemberlist(true)
+		err := g.mlist.Leave(addrChangeLeaveTimeout)
+		g.setRestartingMemberlist(false)
+		if err != nil {
+			log.Warnf("gossip: Unable to leave the cluster: %v", err)
+		}
+		if err := g.mlist.Shutdown(); err != nil {
+			return err
+		}
+	}
+	// Do not create the memberlist again on a failure
+	g.shutDown = true
+	if err := g.startMemberlist(addrs); err != nil {
+		return err
+	}
+	g.shutDown = false
+	return nil
+}
+
+// initAdvertiseAddr sets up the address advertised to the other nodes. If no
+// address is provided and memberlist is bound to all the interfaces, the
+// address of the interface which routes to other hosts is advertised.
+func (g *GossiperImpl) initAdvertiseAddr(addr string) error {
+	if addr != "" {
+		ip, port, err := parseAdvertiseAddr(addr)
+		if err != nil {
+			return err
+		}
+		g.mlConf.AdvertiseAddr = ip.String()
+		g.mlConf.AdvertisePort = port
+		g.updateSelfAdvertiseAddr(addr)
+		return nil
+	}
+
+	bindIp := net.ParseIP(g.mlConf.BindAddr)
+	if g.mlConf.BindAddr != "" && bindIp != nil && !bindIp.IsUnspecified() {
+		// Memberlist advertises the bind address
+		g.updateSelfAdvertiseAddr(net.JoinHostPort(g.mlConf.BindAddr, strconv.Itoa(g.mlConf.BindPort)))
+		return nil
+	}
+
+	ipv6 := bindIp != nil && bindIp.To4() == nil
+	ip, err := detectAdvertiseIP(ipv6)
+	if err != nil {
+		return fmt.Errorf("gossip: Unable to detect the address to advertise: %v", err)
+	}
+	log.Infof("gossip: Bound to all interfaces. Advertising address %v", ip)
+	g.mlConf.AdvertiseAddr = ip.String()
+	g.mlConf.AdvertisePort = g.mlConf.BindPort
+	g.updateSelfAdvertiseAddr(net.JoinHostPort(ip.String(), strconv.Itoa(g.mlConf.BindPort)))
+	return nil
+}
+
+// parseAdvertiseAddr parses an ip:port address. Memberlist can only
+// advertise ip addresses.
+func parseAdvertiseAddr(addr string) (net.IP, int, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gossip: Invalid advertise address %v: %v", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		return nil, 0, fmt.Errorf("gossip: Invalid advertise address %v: not an ip address", addr)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gossip: Invalid advertise address %v: %v", addr, err)
+	}
+	return ip, int(portNum), nil
+}
+
+// detectAdvertiseIP returns the ip of the interface which routes to other
+// hosts. If there is no route, the first suitable interface address is used.
+func detectAdvertiseIP(ipv6 bool) (net.IP, error) {
+	probeAddr := routeProbeAddrV4
+	if ipv6 {
+		probeAddr = routeProbeAddrV6
+	}
+	// Connecting a UDP socket only selects the route and does not send
+	// any packets
+	if conn, err := net.Dial("udp", probeAddr); err == nil {
+		ip := conn.LocalAddr().(*net.UDPAddr).IP
+		conn.Close()
+		if !ip.IsLoopback() && !ip.IsUnspecified() {
+			return ip, nil
+		}
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	if ip := selectAdvertiseIP(addrs, ipv6); ip != nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("no routable interface address found")
+}
+
+// selectAdvertiseIP returns the first global unicast address of the required
+// family, preferring private addresses
+func selectAdvertiseIP(addrs []net.Addr, ipv6 bool) net.IP {
+	var public net.IP
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		default:
+			continue
+		}
+		if (ip.To4() == nil) != ipv6 || !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip.IsPrivate() {
+			return ip
+		}
+		if public == nil {
+			public = ip
+		}
+	}
+	return public
+}
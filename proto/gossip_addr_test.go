@@ -0,0 +1,131 @@
+package proto
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAdvertiseAddr(t *testing.T) {
+	printTestInfo()
+
+	ip, port, err := parseAdvertiseAddr("10.0.0.1:9002")
+	require.NoError(t, err, "Failed to parse address")
+	require.Equal(t, "10.0.0.1", ip.String())
+	require.Equal(t, 9002, port)
+
+	ip, port, err = parseAdvertiseAddr("[fd00::1]:9002")
+	require.NoError(t, err, "Failed to parse address")
+	require.Equal(t, "fd00::1", ip.String())
+	require.Equal(t, 9002, port)
+
+	for _, addr := range []string{"10.0.0.1", "node1:9002", "0.0.0.0:9002", "10.0.0.1:port", "10.0.0.1:70000"} {
+		_, _, err = parseAdvertiseAddr(addr)
+		require.Error(t, err, "Expected error for %v", addr)
+	}
+}
+
+func TestSelectAdvertiseIP(t *testing.T) {
+	printTestInfo()
+
+	ipNet := func(ip string) net.Addr {
+		return &net.IPNet{IP: net.ParseIP(ip)}
+	}
+	addrs := []net.Addr{
+		ipNet("127.0.0.1"),
+		ipNet("169.254.1.1"),
+		ipNet("8.8.8.8"),
+		ipNet("192.168.1.10"),
+		ipNet("::1"),
+		ipNet("fe80::1"),
+		ipNet("2001:4860::1"),
+		ipNet("fd00::10"),
+	}
+	require.Equal(t, "192.168.1.10", selectAdvertiseIP(addrs, false).String())
+	require.Equal(t, "fd00::10", selectAdvertiseIP(addrs, true).String())
+	require.Equal(t, "8.8.8.8", selectAdvertiseIP(addrs[:3], false).String())
+	require.Nil(t, selectAdvertiseIP(addrs[:2], false))
+}
+
+func TestGossipDelegateAdvertiseAddr(t *testing.T) {
+	printTestInfo()
+
+	peers := map[types.NodeId]types.NodeUpdate{
+		"0": {Addr: "127.0.0.1:9000", QuorumMember: true},
+		"1": {Addr: "127.0.0.2:9000", QuorumMember: true},
+	}
+	gd := newTestGossipDelegate("0", peers, types.QUORUM_PROVIDER_DEFAULT)
+
+	gs := NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateSelfAdvertiseAddr("10.0.0.2:9000")
+	require.Equal(t, "10.0.0.2:9000", gs.MetaInfo().Addr)
+	gd.NotifyJoin(newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo()))
+	nodeInfo, err := gd.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, "10.0.0.2:9000", nodeInfo.Addr)
+
+	// Node re-announces a new address
+	gs.updateSelfAdvertiseAddr("10.0.0.3:9000")
+	gd.NotifyUpdate(newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo()))
+	nodeInfo, err = gd.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, "10.0.0.3:9000", nodeInfo.Addr)
+
+	// Nodes which do not advertise an address keep the configured one
+	gs = NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gd.NotifyUpdate(newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo()))
+	nodeInfo, err = gd.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, "10.0.0.3:9000", nodeInfo.Addr)
+}
+
+func TestGossiperUpdateAdvertiseAddr(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{"127.0.0.1:9975", "127.0.0.1:9976"}
+	peers := getNodeUpdateMap(nodesIp)
+	g0, err := NewGossiperImpl(nodesIp[0], "0", []string{}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+	defer g0.Stop(0)
+	g0.UpdateCluster(peers)
+	g1, err := NewGossiperImpl(nodesIp[1], "1", []string{nodesIp[0]}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+	defer g1.Stop(0)
+	g1.UpdateCluster(peers)
+	require.Equal(t, nodesIp[1], g1.GetAdvertiseAddr())
+
+	require.Error(t, g1.UpdateAdvertiseAddr("localhost:9976"), "Expected an error for a DNS name")
+
+	// The address is changed while gossiping
+	newAddr := "127.0.0.2:9976"
+	require.NoError(t, g1.UpdateAdvertiseAddr(newAddr), "Failed to update address")
+	require.Equal(t, newAddr, g1.GetAdvertiseAddr())
+	require.Equal(t, types.NODE_STATUS_UP, g1.GetSelfStatus(), "Expected node to stay up")
+
+	// The other node converges on the new address
+	converged := func() bool {
+		nodeInfo, err := g0.GetLocalNodeInfo("1")
+		if err != nil || nodeInfo.Addr != newAddr || nodeInfo.Status != types.NODE_STATUS_UP {
+			return false
+		}
+		for _, member := range g0.Members(types.MemberFilter{}) {
+			if member.Id == "1" {
+				return member.MemberlistState == types.MEMBERLIST_STATE_ALIVE && member.Addr == "127.0.0.2"
+			}
+		}
+		return false
+	}
+	for i := 0; i < 60 && !converged(); i++ {
+		time.Sleep(time.Second)
+	}
+	require.True(t, converged(), "Node did not converge on the new address")
+	require.Contains(t, g0.GetNodes(), "127.0.0.2")
+	require.NoError(t, g1.Stop(0), "Failed to stop gossiper")
+
+	// Start uses the configured address again
+	require.NoError(t, g1.UpdateAdvertiseAddr("127.0.0.3:9976"), "Failed to update address")
+	require.Equal(t, "127.0.0.3:9976", g1.GetAdvertiseAddr())
+}
@@ -0,0 +1,31 @@
+package proto
+
+import (
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// startArbiterRenewal periodically re-evaluates quorum, which renews the
+// arbiter lease held by our side of the cluster. A node whose side loses
+// the lease drops out of quorum.
+func (gd *GossipDelegate) startArbiterRenewal(leaseTTL time.Duration) {
+	if leaseTTL == 0 {
+		leaseTTL = types.DEFAULT_ARBITER_LEASE_TTL
+	}
+	stopCh := gd.getStateStopCh()
+	gd.routines.Add(1)
+	go func() {
+		defer gd.routines.Done()
+		ticker := time.NewTicker(leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				gd.triggerStateEvent(types.UPDATE_CLUSTER_SIZE)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+package proto
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+func (g *GossiperImpl) MigrateClusterId(clusterId string, window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("gossip: Invalid cluster id migration window %v", window)
+	}
+	oldClusterId := g.GetClusterId()
+	if err := g.startClusterIdMigration(clusterId, time.Now().Add(window)); err != nil {
+		return err
+	}
+	log.Infof("gossip: Migrating from cluster id %v to %v. Accepting the old cluster id for %v",
+		oldClusterId, clusterId, window)
+	g.peerClusterIdsLock.Lock()
+	g.clusterIdMigrated = false
+	g.peerClusterIdsLock.Unlock()
+
+	g.joinLock.Lock()
+	if g.clusterIdMigrationTimer != nil {
+		g.clusterIdMigrationTimer.Stop()
+	}
+	g.clusterIdMigrationTimer = time.AfterFunc(window, g.endClusterIdMigration)
+	g.broadcastClusterId()
+	g.joinLock.Unlock()
+
+	g.checkClusterIdMigration()
+	return nil
+}
+
+// endClusterIdMigration is invoked when the migration window closes. The
+// peers which still use the old cluster id are evicted.
+func (g *GossiperImpl) endClusterIdMigration() {
+	status := g.GetClusterIdMigrationStatus()
+	if !status.Complete {
+		for id, clusterId := range status.NodeClusterIds {
+			if clusterId != status.NewClusterId {
+				log.Warnf("gossip: Node %v did not migrate to cluster id %v", id, status.NewClusterId)
+			}
+		}
+	}
+	log.Infof("gossip: Not accepting the old cluster id %v anymore", status.OldClusterId)
+	g.evictPrevClusterIdPeers(status)
+
+	g.joinLock.Lock()
+	defer g.joinLock.Unlock()
+	// Stop advertising the old cluster id
+	g.broadcastClusterId()
+}
+
+// broadcastClusterId re-announces our meta data with the cluster ids we
+// accept. joinLock must be held.
+func (g *GossiperImpl) broadcastClusterId() {
+	if !g.hasJoinedCluster || g.shutDown {
+		return
+	}
+	if err := g.mlist.UpdateNode(metaBroadcastTimeout); err != nil {
+		// The other nodes will still get the cluster id on the next push/pull
+		log.Warnf("gossip: Unable to broadcast cluster id: %v", err)
+	}
+}
+
+func (g *GossiperImpl) stopClusterIdMigrationTimer() {
+	if g.clusterIdMigrationTimer != nil {
+		g.clusterIdMigrationTimer.Stop()
+		g.clusterIdMigrationTimer = nil
+	}
+}
+
+// evictPrevClusterIdPeers removes the peers which still use the old cluster
+// id from our gossip store, like the peers with a mismatched gossip
+// version. Their gossip is refused from now on.
+func (gd *GossipDelegate) evictPrevClusterIdPeers(status types.ClusterIdMigrationStatus) {
+	for id, clusterId := range status.NodeClusterIds {
+		if clusterId != status.OldClusterId || id == types.NodeId(gd.nodeId) {
+			continue
+		}
+		log.Warnf("gossip: Evicting node %v which uses the old cluster id %v", id, clusterId)
+		gd.RemoveNode(id)
+	}
+}
+
+// recordPeerClusterId updates our view of the cluster id advertised by a peer
+func (gd *GossipDelegate) recordPeerClusterId(nodeId types.NodeId, clusterId string) {
+	gd.peerClusterIdsLock.Lock()
+	if gd.peerClusterIds == nil {
+		gd.peerClusterIds = make(map[types.NodeId]string)
+	}
+	prevClusterId := gd.peerClusterIds[nodeId]
+	gd.peerClusterIds[nodeId] = clusterId
+	gd.peerClusterIdsLock.Unlock()
+
+	if prevClusterId != "" && prevClusterId != clusterId {
+		log.Infof("gossip: Node %v switched from cluster id %v to %v", nodeId, prevClusterId, clusterId)
+	}
+	gd.checkClusterIdMigration()
+}
+
+// checkClusterIdMigration reports the completion of a cluster id migration
+// once all the nodes advertise the new cluster id
+func (gd *GossipDelegate) checkClusterIdMigration() {
+	status := gd.GetClusterIdMigrationStatus()
+	if !status.Complete {
+		return
+	}
+	gd.peerClusterIdsLock.Lock()
+	if gd.clusterIdMigrated {
+		gd.peerClusterIdsLock.Unlock()
+		return
+	}
+	gd.clusterIdMigrated = true
+	gd.peerClusterIdsLock.Unlock()
+
+	log.Infof("gossip: All nodes migrated from cluster id %v to %v", status.OldClusterId, status.NewClusterId)
+	if gd.onClusterIdMigrated != nil {
+		gd.onClusterIdMigrated(status.NewClusterId)
+	}
+}
+
+// GetClusterIdMigrationStatus returns the progress of the last cluster id
+// migration
+func (gd *GossipDelegate) GetClusterIdMigrationStatus() types.ClusterIdMigrationStatus {
+	prevClusterId, deadline, inProgress := gd.getClusterIdMigration()
+	clusterId := gd.GetClusterId()
+	status := types.ClusterIdMigrationStatus{
+		OldClusterId:   prevClusterId,
+		NewClusterId:   clusterId,
+		Deadline:       deadline,
+		InProgress:     inProgress,
+		NodeClusterIds: make(map[types.NodeId]string),
+	}
+	if prevClusterId == "" {
+		// No migration
+		return status
+	}
+
+	nodeInfoMap := gd.GetLocalState()
+	status.Complete = true
+	gd.peerClusterIdsLock.Lock()
+	defer gd.peerClusterIdsLock.Unlock()
+	for id := range nodeInfoMap {
+		nodeClusterId := clusterId
+		if id != types.NodeId(gd.nodeId) {
+			nodeClusterId = gd.peerClusterIds[id]
+		}
+		status.NodeClusterIds[id] = nodeClusterId
+		if nodeClusterId != clusterId {
+			status.Complete = false
+		}
+	}
+	return status
+}
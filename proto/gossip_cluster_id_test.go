@@ -0,0 +1,83 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGossipDelegateClusterIdMigration(t *testing.T) {
+	printTestInfo()
+
+	newNode := func(id types.NodeId, clusterId string) *memberlist.Node {
+		gs := NewGossipStore(id, types.GOSSIP_VERSION_2, clusterId, "")
+		return newTestMemberlistNodeWithMeta(t, id, gs.MetaInfo())
+	}
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	migrated := make(chan string, 1)
+	gd.onClusterIdMigrated = func(clusterId string) {
+		migrated <- clusterId
+	}
+	require.False(t, gd.GetClusterIdMigrationStatus().Complete, "Unexpected migration without one")
+
+	require.Error(t, gd.startClusterIdMigration(DEFAULT_CLUSTER_ID, time.Now().Add(time.Minute)),
+		"Expected error migrating to the same cluster id")
+	require.NoError(t, gd.startClusterIdMigration("new-cluster", time.Now().Add(time.Minute)))
+	require.Error(t, gd.startClusterIdMigration("other-cluster", time.Now().Add(time.Minute)),
+		"Expected error while a migration is in progress")
+	require.Equal(t, "new-cluster", gd.GetClusterId())
+	require.Equal(t, DEFAULT_CLUSTER_ID, gd.MetaInfo().PrevClusterId)
+
+	// Both cluster ids are accepted during the window
+	node1 := newNode("1", DEFAULT_CLUSTER_ID)
+	require.NoError(t, gd.gossipChecks(node1), "Expected old cluster id to be accepted")
+	require.NoError(t, gd.gossipChecks(newNode("2", "new-cluster")), "Expected new cluster id to be accepted")
+	require.Error(t, gd.gossipChecks(newNode("2", "other-cluster")), "Expected cluster id mismatch")
+
+	// A node which is not migrating accepts the nodes migrating from its cluster id
+	peerGd := newTestGossipDelegate("1", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	require.NoError(t, peerGd.gossipChecks(newTestMemberlistNodeWithMeta(t, "0", gd.MetaInfo())),
+		"Expected migrating node to be accepted")
+
+	gd.NotifyJoin(node1)
+	gd.NotifyJoin(newNode("2", "new-cluster"))
+	status := gd.GetClusterIdMigrationStatus()
+	require.True(t, status.InProgress)
+	require.False(t, status.Complete)
+	require.Equal(t, map[types.NodeId]string{
+		"0": "new-cluster",
+		"1": DEFAULT_CLUSTER_ID,
+		"2": "new-cluster",
+	}, status.NodeClusterIds)
+
+	gd.NotifyUpdate(newNode("1", "new-cluster"))
+	require.True(t, gd.GetClusterIdMigrationStatus().Complete, "Expected migration to be complete")
+	select {
+	case clusterId := <-migrated:
+		require.Equal(t, "new-cluster", clusterId)
+	default:
+		require.Fail(t, "Expected migration to be reported")
+	}
+	gd.NotifyUpdate(newNode("2", "new-cluster"))
+	require.Len(t, migrated, 0, "Expected migration to be reported once")
+
+	// The old cluster id is rejected once the window closes
+	gd.prevClusterIdDeadline = time.Now()
+	require.Error(t, gd.gossipChecks(newNode("1", DEFAULT_CLUSTER_ID)), "Expected old cluster id to be rejected")
+	require.Empty(t, gd.MetaInfo().PrevClusterId)
+	require.False(t, gd.GetClusterIdMigrationStatus().InProgress)
+
+	// The nodes which still use the old cluster id are evicted
+	gd.recordPeerClusterId("2", DEFAULT_CLUSTER_ID)
+	gd.evictPrevClusterIdPeers(gd.GetClusterIdMigrationStatus())
+	_, err := gd.GetLocalNodeInfo("2")
+	require.Error(t, err, "Expected node to be evicted when the window closes")
+	for _, id := range []types.NodeId{"0", "1"} {
+		_, err = gd.GetLocalNodeInfo(id)
+		require.NoError(t, err, "Expected node %v to be kept", id)
+	}
+}
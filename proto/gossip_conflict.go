@@ -0,0 +1,205 @@
+package proto
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	ml "github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// NotifyConflict is invoked by memberlist when a node name is seen with
+// two different addresses, which happens when two nodes are started with
+// the same node id. Memberlist keeps the existing node and ignores the other.
+func (g *GossiperImpl) NotifyConflict(existing, other *ml.Node) {
+	dupErr := &types.DuplicateNodeIdError{
+		NodeId:       types.NodeId(g.parseMemberlistNodeName(existing)),
+		ExistingAddr: net.JoinHostPort(existing.Addr.String(), strconv.Itoa(int(existing.Port))),
+		OtherAddr:    net.JoinHostPort(other.Addr.String(), strconv.Itoa(int(other.Port))),
+	}
+	if !g.markConflictSeen(dupErr, true) {
+		// Memberlist notifies us for every message from the other node
+		return
+	}
+
+	// Memberlist holds its locks while notifying us, so check the nodes in
+	// the background
+	existingNode := *existing
+	otherNode := *other
+	g.conflictChecks.add()
+	go func() {
+		defer g.conflictChecks.done()
+		if existingNode.Name == g.mlConf.Name {
+			// Another node claims our node id
+			g.checkSelfConflict(dupErr, &otherNode)
+		} else {
+			g.checkPeerConflict(dupErr, &existingNode, &otherNode)
+		}
+	}()
+}
+
+// pendingTasks counts the tasks in progress. Unlike a sync.WaitGroup, tasks
+// can be added while another go routine waits for them to finish.
+type pendingTasks struct {
+	lock  sync.Mutex
+	count int
+	// idle is closed when count drops to zero
+	idle chan struct{}
+}
+
+func (p *pendingTasks) add() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.count == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.count++
+}
+
+func (p *pendingTasks) done() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.count--
+	if p.count == 0 {
+		close(p.idle)
+	}
+}
+
+// wait returns a channel which is closed once no tasks are in progress
+func (p *pendingTasks) wait() <-chan struct{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.count == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return p.idle
+}
+
+// checkSelfConflict reports another node claiming our node id. If it
+// started before us and is alive we are the duplicate, and stop gossiping
+// when configured to do so.
+func (g *GossiperImpl) checkSelfConflict(dupErr *types.DuplicateNodeIdError, other *ml.Node) {
+	otherMeta, err := g.nodeMetaInfo(other)
+	if err != nil {
+		// We cannot tell which node started first, so do not evict ourselves
+		log.Warnf("gossip: Unable to decode the meta data of the node at %v: %v", dupErr.OtherAddr, err)
+		g.reportDuplicateNodeId(dupErr)
+		return
+	}
+	if !otherMeta.StartTs.Before(g.MetaInfo().StartTs) {
+		// The other node is the duplicate
+		g.reportDuplicateNodeId(dupErr)
+		return
+	}
+
+	// The other address could be a stale entry left by an earlier run
+	// of this node. Only a live node answers pings for our name.
+	otherAddr := &net.UDPAddr{IP: other.Addr, Port: int(other.Port)}
+	if _, err := g.getMemberlist().Ping(other.Name, otherAddr); err != nil {
+		log.Infof("gossip: Ignoring conflicting address %v for our node id: %v", dupErr.OtherAddr, err)
+		g.markConflictSeen(dupErr, false)
+		return
+	}
+	g.reportDuplicateNodeId(dupErr)
+
+	if !g.refuseDuplicateNodeId {
+		return
+	}
+	log.Errorf("gossip: Node %v at %v started before us. Stopping gossip.", dupErr.NodeId, dupErr.OtherAddr)
+	g.duplicateNodeIdLock.Lock()
+	g.duplicateNodeIdErr = dupErr
+	g.duplicateNodeIdLock.Unlock()
+
+	g.joinLock.Lock()
+	defer g.joinLock.Unlock()
+	if g.shutDown {
+		return
+	}
+	g.stopRejoiner()
+	g.stopResolver()
+	g.stopFederation(0)
+	// Do not leave as the leave message would mark the other node dead
+	if err := g.mlist.Shutdown(); err != nil {
+		log.Warnf("gossip: Unable to shutdown memberlist: %v", err)
+	}
+	g.shutDown = true
+	g.triggerStateEvent(types.SELF_LEAVE)
+}
+
+// checkPeerConflict reports two nodes claiming the node id of a peer.
+// Memberlist also notifies us about dead entries, so a peer restarted at
+// another address is not reported.
+func (g *GossiperImpl) checkPeerConflict(
+	dupErr *types.DuplicateNodeIdError,
+	existing *ml.Node,
+	other *ml.Node,
+) {
+	existingMeta, existingErr := g.nodeMetaInfo(existing)
+	otherMeta, otherErr := g.nodeMetaInfo(other)
+	if existingErr == nil && otherErr == nil &&
+		!existingMeta.StartTs.IsZero() && existingMeta.StartTs.Equal(otherMeta.StartTs) {
+		// The same run of the peer moved to another address
+		log.Infof("gossip: Node %v moved from %v to %v", dupErr.NodeId, dupErr.ExistingAddr, dupErr.OtherAddr)
+		g.markConflictSeen(dupErr, false)
+		return
+	}
+	if existingErr != nil || otherErr != nil {
+		// We cannot tell the runs of the peer apart
+		g.reportDuplicateNodeId(dupErr)
+		return
+	}
+
+	// The existing address could be a stale entry left by an earlier run
+	// of the peer. Only a live node answers pings for its name.
+	existingAddr := &net.UDPAddr{IP: existing.Addr, Port: int(existing.Port)}
+	if _, err := g.getMemberlist().Ping(existing.Name, existingAddr); err != nil {
+		log.Infof("gossip: Ignoring conflicting address %v for node %v: %v",
+			dupErr.ExistingAddr, dupErr.NodeId, err)
+		g.markConflictSeen(dupErr, false)
+		return
+	}
+	g.reportDuplicateNodeId(dupErr)
+}
+
+// markConflictSeen records whether a conflict has been handled. It returns
+// false if the conflict was already seen.
+func (g *GossiperImpl) markConflictSeen(dupErr *types.DuplicateNodeIdError, seen bool) bool {
+	g.duplicateNodeIdLock.Lock()
+	defer g.duplicateNodeIdLock.Unlock()
+
+	key := dupErr.ExistingAddr + "/" + dupErr.OtherAddr
+	if !seen {
+		delete(g.conflictsSeen, key)
+		return true
+	}
+	if g.conflictsSeen[key] {
+		return false
+	}
+	if g.conflictsSeen == nil {
+		g.conflictsSeen = make(map[string]bool)
+	}
+	g.conflictsSeen[key] = true
+	return true
+}
+
+func (g *GossiperImpl) reportDuplicateNodeId(dupErr *types.DuplicateNodeIdError) {
+	log.Errorf("%v", dupErr)
+	if g.onDuplicateNodeId != nil {
+		g.onDuplicateNodeId(dupErr)
+	}
+}
+
+// getDuplicateNodeIdErr returns an error if this node stopped gossiping
+// because another node with the same node id started before it
+func (g *GossiperImpl) getDuplicateNodeIdErr() error {
+	g.duplicateNodeIdLock.Lock()
+	defer g.duplicateNodeIdLock.Unlock()
+	if g.duplicateNodeIdErr == nil {
+		return nil
+	}
+	return g.duplicateNodeIdErr
+}
@@ -0,0 +1,147 @@
+package proto
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	ml "github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testConflicts struct {
+	sync.Mutex
+	errs []*types.DuplicateNodeIdError
+}
+
+func (c *testConflicts) onDuplicateNodeId(err *types.DuplicateNodeIdError) {
+	c.Lock()
+	defer c.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func (c *testConflicts) get() []*types.DuplicateNodeIdError {
+	c.Lock()
+	defer c.Unlock()
+	return append([]*types.DuplicateNodeIdError{}, c.errs...)
+}
+
+func newGossiperImplWithConflicts(
+	ip string,
+	selfNodeId types.NodeId,
+	knownIps []string,
+	conflicts *testConflicts,
+) (*GossiperImpl, error) {
+	knownNodesMap := make(map[string]string)
+	for _, knownIp := range knownIps {
+		knownNodesMap[knownIp] = ""
+	}
+	return newGossiperImpl(ip, selfNodeId, "", knownNodesMap, types.GOSSIP_VERSION_2,
+		DEFAULT_CLUSTER_ID, types.QUORUM_PROVIDER_DEFAULT, nil,
+		func(config *types.GossipStartConfiguration) {
+			config.OnDuplicateNodeId = conflicts.onDuplicateNodeId
+			config.RefuseDuplicateNodeId = true
+		})
+}
+
+func TestGossiperDuplicateNodeId(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9960",
+		"127.0.0.2:9961",
+	}
+	firstConflicts := &testConflicts{}
+	gFirst, err := newGossiperImplWithConflicts(nodesIp[0], "0", []string{}, firstConflicts)
+	require.NoError(t, err, "Failed to start gossiper")
+
+	// Second instance with the same node id is refused
+	secondConflicts := &testConflicts{}
+	gSecond, err := newGossiperImplWithConflicts(nodesIp[1], "0", []string{nodesIp[0]}, secondConflicts)
+	require.Error(t, err, "Expected duplicate node id error")
+	dupErr, ok := err.(*types.DuplicateNodeIdError)
+	require.True(t, ok, "Unexpected error type %T", err)
+	require.Equal(t, types.NodeId("0"), dupErr.NodeId)
+	require.Equal(t, nodesIp[1], dupErr.ExistingAddr)
+	require.Equal(t, nodesIp[0], dupErr.OtherAddr)
+	require.Len(t, secondConflicts.get(), 1)
+	require.Error(t, gSecond.Stop(0), "Expected second instance to be stopped")
+
+	// First instance is notified and keeps running
+	for i := 0; i < 10 && len(firstConflicts.get()) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.NotEmpty(t, firstConflicts.get(), "Expected first instance to be notified")
+	require.Equal(t, nodesIp[1], firstConflicts.get()[0].OtherAddr)
+	require.Len(t, gFirst.GetNodes(), 1)
+	require.NoError(t, gFirst.Stop(0), "Failed to stop gossiper")
+}
+
+func TestGossiperDuplicateNodeIdUnknownMeta(t *testing.T) {
+	printTestInfo()
+
+	conflicts := &testConflicts{}
+	g := &GossiperImpl{
+		onDuplicateNodeId:     conflicts.onDuplicateNodeId,
+		refuseDuplicateNodeId: true,
+	}
+	dupErr := &types.DuplicateNodeIdError{
+		NodeId:       "0",
+		ExistingAddr: "127.0.0.1:9962",
+		OtherAddr:    "127.0.0.2:9963",
+	}
+	// A node whose meta data cannot be decoded is reported, but we do not
+	// know which node started first and keep gossiping
+	g.checkSelfConflict(dupErr, &ml.Node{Name: "0", Meta: []byte("invalid")})
+	require.Len(t, conflicts.get(), 1)
+	require.NoError(t, g.getDuplicateNodeIdErr())
+	require.False(t, g.shutDown, "Expected gossip to keep running")
+}
+
+func TestGossiperPeerConflict(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9977",
+		"127.0.0.2:9978",
+	}
+	conflicts := &testConflicts{}
+	g, err := newGossiperImplWithConflicts(nodesIp[0], "0", []string{}, conflicts)
+	require.NoError(t, err, "Failed to start gossiper")
+
+	peerNode := func(ip string, startTs time.Time) *ml.Node {
+		meta, err := g.convertToBytes(types.NodeMetaInfo{StartTs: startTs})
+		require.NoError(t, err)
+		return &ml.Node{
+			Name: "1" + types.GOSSIP_VERSION_2,
+			Addr: net.ParseIP(ip),
+			Port: 9979,
+			Meta: meta,
+		}
+	}
+	dupErr := &types.DuplicateNodeIdError{
+		NodeId:       "1",
+		ExistingAddr: "127.0.0.3:9979",
+		OtherAddr:    "127.0.0.4:9979",
+	}
+	startTs := time.Now()
+
+	// The same run of the peer moved to another address
+	g.checkPeerConflict(dupErr, peerNode("127.0.0.3", startTs), peerNode("127.0.0.4", startTs))
+	require.Empty(t, conflicts.get(), "Expected moved peer not to be reported")
+
+	// The peer restarted at another address and nothing answers at the
+	// existing address
+	g.checkPeerConflict(dupErr, peerNode("127.0.0.3", startTs), peerNode("127.0.0.4", startTs.Add(time.Second)))
+	require.Empty(t, conflicts.get(), "Expected restarted peer not to be reported")
+
+	// We cannot tell the runs of the peer apart
+	unknown := peerNode("127.0.0.4", startTs)
+	unknown.Meta = []byte("invalid")
+	g.checkPeerConflict(dupErr, peerNode("127.0.0.3", startTs), unknown)
+	require.Len(t, conflicts.get(), 1)
+
+	require.NoError(t, g.Stop(0), "Failed to stop gossiper")
+}
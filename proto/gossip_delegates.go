@@ -3,6 +3,7 @@ package proto
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -10,13 +11,15 @@ import (
 	"github.com/hashicorp/memberlist"
 	"github.com/sirupsen/logrus"
 
+	"github.com/libopenstorage/gossip/pkg/damping"
 	"github.com/libopenstorage/gossip/pkg/probation"
 	"github.com/libopenstorage/gossip/proto/state"
 	"github.com/libopenstorage/gossip/types"
 )
 
 const (
-	suspectNodeDownTimeout = 1 * time.Minute
+	suspectNodeDownTimeout   = 1 * time.Minute
+	flapDampingCheckInterval = 1 * time.Second
 )
 
 type GossipDelegate struct {
@@ -31,15 +34,59 @@ type GossipDelegate struct {
 	// current State object
 	currentState     state.State
 	currentStateLock sync.Mutex
+	// stateStopCh stops the state event handler and the quorum timers
+	stateStopCh chan struct{}
+	// stateHistory records the state transitions of this node
+	stateHistory *stateHistory
+	// fencer runs the fencing hooks when this node drops out of quorum
+	fencer fencer
+	// routines tracks the background go routines which exit when
+	// gossip is stopped
+	routines sync.WaitGroup
 	// quorum timeout to change the quorum status of a node
-	quorumTimeout            time.Duration
-	timeoutVersion           uint64
-	timeoutVersionLock       sync.Mutex
+	quorumTimeout time.Duration
+	// quorumRecoveryInterval is the time for which quorum must hold before
+	// this node moves from NOT_IN_QUORUM back to UP
+	quorumRecoveryInterval time.Duration
+	// quorumTimer and recoveryTimer are the timers of the state machine.
+	// They are only used by the state event handler.
+	quorumTimer   stateTimer
+	recoveryTimer stateTimer
+	// wasUp is true if this node has been up since gossip started
+	wasUp                    bool
 	nodeDownProbationManager probation.Probation
 	quorumProvider           state.Quorum
+	// flapDampingManager tracks peer nodes which keep flapping.
+	// It is nil if flap damping is disabled.
+	flapDampingManager damping.Damping
+	// flapDampingClock is the clock of flap damping. It defaults to
+	// time.Now and is only set by tests.
+	flapDampingClock func() time.Time
+	// dampedNodesAlive tracks whether memberlist sees a damped node alive
+	dampedNodesAlive     map[string]bool
+	dampedNodesAliveLock sync.Mutex
 	// ping is a callback function from Gossiper that uses memberlist
 	// apis to ping a peer node
 	ping func(types.NodeId, string) (time.Duration, error)
+	// peerDown is an optional callback function from Gossiper which is
+	// invoked when memberlist reports a peer node as left or failed
+	peerDown func(types.NodeId)
+	// peerVersions tracks the gossip versions run by the peer nodes
+	peerVersions     map[types.NodeId]types.NodeVersion
+	peerVersionsLock sync.Mutex
+	// peerClusterIds tracks the cluster ids advertised by the peer nodes
+	peerClusterIds     map[types.NodeId]string
+	peerClusterIdsLock sync.Mutex
+	// clusterIdMigrated is set once all the nodes advertise the
+	// cluster id being migrated to
+	clusterIdMigrated bool
+	// onClusterIdMigrated is an optional callback invoked when a cluster
+	// id migration completes
+	onClusterIdMigrated func(clusterId string)
+	// restartingMemberlist is true while memberlist is restarted to change
+	// the advertise address. This node does not go down meanwhile.
+	restartingMemberlist     bool
+	restartingMemberlistLock sync.Mutex
 }
 
 func (gd *GossipDelegate) InitGossipDelegate(
@@ -81,9 +128,93 @@ func (gd *GossipDelegate) InitCurrentState(
 		quorumProvider,
 	)
 	gd.quorumProvider = quorumProvider
+	gd.wasUp = false
 	// Start the go routine which handles all the events
 	// and changes state of the node
-	go gd.handleStateEvents()
+	stopCh := make(chan struct{})
+	gd.currentStateLock.Lock()
+	gd.stateStopCh = stopCh
+	gd.currentStateLock.Unlock()
+	gd.routines.Add(1)
+	go gd.handleStateEvents(stopCh)
+}
+
+// stopStateHandler stops the state event handler, the quorum timers, the
+// probation of suspected down nodes and the flap damping of the peers
+func (gd *GossipDelegate) stopStateHandler() {
+	gd.currentStateLock.Lock()
+	if gd.stateStopCh != nil {
+		select {
+		case <-gd.stateStopCh:
+		default:
+			// Events triggered after this are dropped
+			close(gd.stateStopCh)
+		}
+	}
+	gd.currentStateLock.Unlock()
+	if gd.nodeDownProbationManager != nil {
+		gd.nodeDownProbationManager.Stop()
+	}
+	if gd.flapDampingManager != nil {
+		gd.flapDampingManager.Stop()
+		gd.flapDampingManager = nil
+	}
+}
+
+func (gd *GossipDelegate) getStateStopCh() chan struct{} {
+	gd.currentStateLock.Lock()
+	defer gd.currentStateLock.Unlock()
+	return gd.stateStopCh
+}
+
+// flapDampingConfigWithDefaults returns the flap damping configuration with
+// the defaults applied to the fields which are not set
+func flapDampingConfigWithDefaults(config types.FlapDampingConfig) (types.FlapDampingConfig, error) {
+	if config.SuppressThreshold == 0 {
+		config.SuppressThreshold = types.DEFAULT_FLAP_SUPPRESS_THRESHOLD
+	}
+	if config.ReuseThreshold == 0 {
+		config.ReuseThreshold = types.DEFAULT_FLAP_REUSE_THRESHOLD
+	}
+	if config.MaxPenalty == 0 {
+		config.MaxPenalty = types.DEFAULT_FLAP_MAX_PENALTY
+	}
+	if config.HalfLife == 0 {
+		config.HalfLife = types.DEFAULT_FLAP_HALF_LIFE
+	}
+	if config.ReuseThreshold >= config.SuppressThreshold {
+		return config, fmt.Errorf("gossip: Flap damping reuse threshold %v should be lower "+
+			"than the suppress threshold %v", config.ReuseThreshold, config.SuppressThreshold)
+	}
+	if config.MaxPenalty < config.SuppressThreshold {
+		return config, fmt.Errorf("gossip: Flap damping max penalty %v should not be lower "+
+			"than the suppress threshold %v", config.MaxPenalty, config.SuppressThreshold)
+	}
+	return config, nil
+}
+
+func (gd *GossipDelegate) InitFlapDamping(config types.FlapDampingConfig) error {
+	if config.Penalty == 0 {
+		// Flap damping disabled
+		return nil
+	}
+	config, err := flapDampingConfigWithDefaults(config)
+	if err != nil {
+		return err
+	}
+	now := gd.flapDampingClock
+	if now == nil {
+		now = time.Now
+	}
+	gd.dampedNodesAlive = make(map[string]bool)
+	gd.flapDampingManager = damping.NewDampingManagerWithClock(
+		"node-flap-damping-manager",
+		config,
+		flapDampingCheckInterval,
+		gd.flapDampingReuseOnDampedNode,
+		now,
+	)
+	return gd.flapDampingManager.Start()
 }
 
 func (gd *GossipDelegate) updateGossipTs() {
@@ -92,24 +223,31 @@ func (gd *GossipDelegate) updateGossipTs() {
 	gd.lastGossipTs = time.Now()
 }
 
+// nodeMetaInfo returns the meta information published by a peer node
+func (gd *GossipDelegate) nodeMetaInfo(node *memberlist.Node) (types.NodeMetaInfo, error) {
+	var nodeMeta types.NodeMetaInfo
+	err := gd.convertFromBytes(node.Meta, &nodeMeta)
+	return nodeMeta, err
+}
+
 func (gd *GossipDelegate) gossipChecks(node *memberlist.Node) error {
 	// Check the gossip version of other node
 	var nodeMeta types.NodeMetaInfo
-	nodeName := gd.parseMemberlistNodeName(node.Name)
+	nodeName := gd.parseMemberlistNodeName(node)
 	err := gd.convertFromBytes(node.Meta, &nodeMeta)
 	if err != nil {
 		err = fmt.Errorf("gossip: Error in unmarshalling peer's meta data. Error : %v", err.Error())
 	} else {
-		if nodeMeta.GossipVersion != gd.GetGossipVersion() {
+		if _, versionErr := gd.negotiatePeerVersion(nodeMeta); versionErr != nil {
 			// Version Mismatch
 			// We do not add this node in our memberlist
 			err = fmt.Errorf("Version mismatch with "+
-				"Node (%v):(%v). Our version: (%v). Their version: (%v)",
-				nodeName, node.Addr, gd.GetGossipVersion(), nodeMeta.GossipVersion)
+				"Node (%v):(%v). Our version: (%v). Their version: (%v). %v",
+				nodeName, node.Addr, gd.GetGossipVersion(), nodeMeta.GossipVersion, versionErr)
 		} else {
 			// Version Match
 			// Check for ClusterId match
-			if nodeMeta.ClusterId != gd.GetClusterId() {
+			if !gd.acceptsClusterId(nodeMeta) {
 				// ClusterId Mismatch
 				// We do not add this node in our memberlist
 				err = fmt.Errorf("(%v) ClusterId mismatch with"+
@@ -131,6 +269,15 @@ func (gd *GossipDelegate) gossipChecks(node *memberlist.Node) error {
 func (gd *GossipDelegate) NodeMeta(limit int) []byte {
 	msg := gd.MetaInfo()
 	msgBytes, _ := gd.convertToBytes(msg)
+	if len(msgBytes) > limit && len(msg.Labels) > 0 {
+		// memberlist refuses meta data over the limit. Publish the
+		// node without its labels instead.
+		logrus.Errorf("gossip: Node meta data size %v exceeds the limit %v. Dropping labels.",
+			len(msgBytes), limit)
+		msg.Labels = nil
+		msg.LabelsTs = time.Time{}
+		msgBytes, _ = gd.convertToBytes(msg)
+	}
 	return msgBytes
 }
 
@@ -166,6 +313,7 @@ func (gd *GossipDelegate) GetBroadcasts(overhead, limit int) [][]byte {
 // data can be sent here. See MergeRemoteState as well. The `join`
 // boolean indicates this is for a join instead of a push/pull.
 func (gd *GossipDelegate) LocalState(join bool) []byte {
+	gd.updateSelfReachability()
 	gd.updateSelfTs()
 
 	// We send our local state of nodeMap
@@ -196,7 +344,11 @@ func (gd *GossipDelegate) MergeRemoteState(buf []byte, join bool) {
 			"Error : %v", err.Error())
 	}
 
-	gd.Update(remoteState)
+	if quorumUpdated := gd.update(remoteState); quorumUpdated {
+		// Nodes in maintenance are not a part of quorum and witnesses
+		// only vote for one side
+		gd.triggerStateEvent(types.UPDATE_CLUSTER_SIZE)
+	}
 	gd.updateGossipTs()
 	return
 }
@@ -205,7 +357,7 @@ func (gd *GossipDelegate) MergeRemoteState(buf []byte, join bool) {
 // The Node argument must not be modified.
 func (gd *GossipDelegate) NotifyJoin(node *memberlist.Node) {
 	// Ignore self NotifyJoin
-	nodeName := gd.parseMemberlistNodeName(node.Name)
+	nodeName := gd.parseMemberlistNodeName(node)
 	if nodeName == gd.nodeId {
 		return
 	}
@@ -217,18 +369,45 @@ func (gd *GossipDelegate) NotifyJoin(node *memberlist.Node) {
 	// Nevertheless we are doing an extra check here.
 	if err := gd.gossipChecks(node); err != nil {
 		gd.RemoveNode(types.NodeId(nodeName))
+		return
+	}
+	gd.updateNodeHeardTs(types.NodeId(nodeName))
+	if nodeMeta, err := gd.nodeMetaInfo(node); err == nil {
+		gd.recordPeerVersion(types.NodeId(nodeName), nodeMeta)
+		gd.recordPeerClusterId(types.NodeId(nodeName), nodeMeta.ClusterId)
+		gd.updateNodeLabels(types.NodeId(nodeName), nodeMeta.Labels, nodeMeta.LabelsTs)
+		if nodeMeta.Addr != "" {
+			gd.updateNodeAddr(types.NodeId(nodeName), nodeMeta.Addr)
+		}
+		gd.applyNodeMaintenance(types.NodeId(nodeName), nodeMeta.Maintenance)
 	}
 }
 
 // NotifyLeave is invoked when a node is detected to have left.
 // The Node argument must not be modified.
 func (gd *GossipDelegate) NotifyLeave(node *memberlist.Node) {
-	nodeName := gd.parseMemberlistNodeName(node.Name)
+	nodeName := gd.parseMemberlistNodeName(node)
+	if nodeName != gd.nodeId && gd.isStalePeerInstance(nodeName, node.Name) {
+		// The peer was restarted with another gossip version
+		logrus.Infof("gossip: Ignoring leave of node %v running an older gossip version", node.Name)
+		return
+	}
 	if nodeName == gd.nodeId {
+		if gd.isRestartingMemberlist() {
+			return
+		}
 		gd.triggerStateEvent(types.SELF_LEAVE)
+	} else if gd.maintenanceNodeLeave(nodeName) {
+		// Node is in maintenance and not a part of quorum
+	} else if gd.gracefulNodeLeave(nodeName) {
+		// Node left the cluster on its own
+	} else if gd.dampNodeLeave(nodeName) {
+		// Node is damped. Do not re-evaluate quorum on every flap
 	} else {
 		if gd.quorumProvider.Type() == types.QUORUM_PROVIDER_FAILURE_DOMAINS {
+			gd.routines.Add(1)
 			go func() {
+				defer gd.routines.Done()
 				isSuspect := gd.isClusterDomainSuspectDown(types.NodeId(nodeName))
 				if isSuspect {
 					gd.setNodeAsSuspectOffline(nodeName)
@@ -240,11 +419,30 @@ func (gd *GossipDelegate) NotifyLeave(node *memberlist.Node) {
 			gd.setNodeOffline(nodeName)
 		}
 	}
+	if nodeName != gd.nodeId && gd.peerDown != nil {
+		gd.routines.Add(1)
+		go func() {
+			defer gd.routines.Done()
+			gd.peerDown(types.NodeId(nodeName))
+		}()
+	}
 
 	gd.updateGossipTs()
 	return
 }
 
+func (gd *GossipDelegate) setRestartingMemberlist(restarting bool) {
+	gd.restartingMemberlistLock.Lock()
+	defer gd.restartingMemberlistLock.Unlock()
+	gd.restartingMemberlist = restarting
+}
+
+func (gd *GossipDelegate) isRestartingMemberlist() bool {
+	gd.restartingMemberlistLock.Lock()
+	defer gd.restartingMemberlistLock.Unlock()
+	return gd.restartingMemberlist
+}
+
 func (gd *GossipDelegate) setNodeAsSuspectOffline(nodeName string) {
 	err := gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_SUSPECT_DOWN)
 	if err != nil {
@@ -272,14 +470,151 @@ func (gd *GossipDelegate) setNodeOffline(nodeName string) {
 	gd.triggerStateEvent(types.NODE_LEAVE)
 }
 
+// maintenanceNodeLeave marks a node in maintenance down when memberlist
+// reports it as left. It returns true if the node is in maintenance, in which
+// case the caller should not act on the leave.
+func (gd *GossipDelegate) maintenanceNodeLeave(nodeName string) bool {
+	nodeInfo, err := gd.GetLocalNodeInfo(types.NodeId(nodeName))
+	if err != nil || !nodeInfo.Maintenance {
+		return false
+	}
+	status := types.NODE_STATUS_DOWN
+	if nodeInfo.Leaving {
+		status = types.NODE_STATUS_LEFT
+	}
+	if err := gd.UpdateNodeStatus(types.NodeId(nodeName), status); err != nil {
+		logrus.Infof("gossip: Could not update status on NotifyLeave : %v", err.Error())
+	}
+	logrus.Infof("gossip: Node %v in maintenance left", nodeName)
+	return true
+}
+
+// gracefulNodeLeave marks a node which announced that it is leaving the
+// cluster as left. It returns true if the node left gracefully, in which case
+// the caller should not treat the leave as a failure.
+func (gd *GossipDelegate) gracefulNodeLeave(nodeName string) bool {
+	nodeInfo, err := gd.GetLocalNodeInfo(types.NodeId(nodeName))
+	if err != nil || !nodeInfo.Leaving {
+		return false
+	}
+	if err := gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_LEFT); err != nil {
+		logrus.Infof("gossip: Could not update status on NotifyLeave : %v", err.Error())
+		return false
+	}
+	logrus.Infof("gossip: Node %v left the cluster. Reason: %v", nodeName, nodeInfo.LeaveReason)
+	if err := gd.nodeDownProbationManager.Remove(gd.nodeNameToProbationID(nodeName)); err != nil {
+		logrus.Warnf("gossip: Unable to remove node %v from probation list: %v", nodeName, err)
+	}
+	gd.triggerStateEvent(types.NODE_LEAVE)
+	return true
+}
+
+// dampNodeLeave records a flap for a node that memberlist reported as left.
+// It returns true if the node is damped, in which case the caller should not
+// act on the leave.
+func (gd *GossipDelegate) dampNodeLeave(nodeName string) bool {
+	if gd.flapDampingManager == nil {
+		return false
+	}
+	nodeInfo, err := gd.GetLocalNodeInfo(types.NodeId(nodeName))
+	if err != nil {
+		return false
+	}
+	suppressed := gd.flapDampingManager.Flap(nodeName)
+	if nodeInfo.Status == types.NODE_STATUS_DAMPED {
+		gd.setDampedNodeAlive(nodeName, false)
+		return true
+	}
+	if !suppressed {
+		return false
+	}
+	if err := gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_DAMPED); err != nil {
+		logrus.Infof("gossip: Could not update status on NotifyLeave : %v", err.Error())
+		return false
+	}
+	logrus.Infof("gossip: Node %v is flapping. Damping it with penalty %v",
+		nodeName, gd.flapDampingManager.Penalty(nodeName))
+	gd.setDampedNodeAlive(nodeName, false)
+	if err := gd.nodeDownProbationManager.Remove(gd.nodeNameToProbationID(nodeName)); err != nil {
+		logrus.Warnf("gossip: Unable to remove damped node %v from probation list: %v", nodeName, err)
+	}
+	// A damped node does not count towards quorum
+	gd.triggerStateEvent(types.NODE_LEAVE)
+	return true
+}
+
+func (gd *GossipDelegate) setDampedNodeAlive(nodeName string, alive bool) {
+	gd.dampedNodesAliveLock.Lock()
+	defer gd.dampedNodesAliveLock.Unlock()
+	gd.dampedNodesAlive[nodeName] = alive
+}
+
+func (gd *GossipDelegate) flapDampingReuseOnDampedNode(nodeName string) error {
+	// Node has been stable long enough for its penalty to decay.
+	// Move it out of the damped state based on what memberlist last
+	// reported for it.
+	gd.dampedNodesAliveLock.Lock()
+	alive := gd.dampedNodesAlive[nodeName]
+	delete(gd.dampedNodesAlive, nodeName)
+	gd.dampedNodesAliveLock.Unlock()
+
+	nodeInfo, err := gd.GetLocalNodeInfo(types.NodeId(nodeName))
+	if err != nil || nodeInfo.Status != types.NODE_STATUS_DAMPED {
+		return nil
+	}
+	if !alive {
+		logrus.Infof("gossip: Node %v is no more damped and is offline", nodeName)
+		return gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_DOWN)
+	}
+	logrus.Infof("gossip: Node %v is no more damped and is online", nodeName)
+	if err := gd.UpdateNodeStatus(types.NodeId(nodeName), aliveStatus(nodeInfo)); err != nil {
+		return err
+	}
+	gd.triggerStateEvent(types.NODE_ALIVE)
+	return nil
+}
+
 // NotifyUpdate is invoked when a node is detected to have
 // updated, usually involving the meta data. The Node argument
 // must not be modified.
-// Note: Currently we do not use memberlists Node meta or modify it.
-// Probably future use ?
 func (gd *GossipDelegate) NotifyUpdate(node *memberlist.Node) {
-	nodeName := gd.parseMemberlistNodeName(node.Name)
+	nodeName := gd.parseMemberlistNodeName(node)
 	logrus.Infof("gossip: Update Notification from %v %v", nodeName, node.Addr)
+	if nodeName == gd.nodeId {
+		return
+	}
+	gd.updateNodeHeardTs(types.NodeId(nodeName))
+	nodeMeta, err := gd.nodeMetaInfo(node)
+	if err != nil {
+		logrus.Infof("gossip: Error in unmarshalling peer's meta data. Error : %v", err.Error())
+		return
+	}
+	if nodeMeta.Leaving {
+		logrus.Infof("gossip: Node %v is leaving the cluster. Reason: %v", nodeName, nodeMeta.LeaveReason)
+	}
+	gd.updateNodeLeaving(types.NodeId(nodeName), nodeMeta.Leaving, nodeMeta.LeaveReason)
+	gd.recordPeerVersion(types.NodeId(nodeName), nodeMeta)
+	gd.recordPeerClusterId(types.NodeId(nodeName), nodeMeta.ClusterId)
+	gd.updateNodeLabels(types.NodeId(nodeName), nodeMeta.Labels, nodeMeta.LabelsTs)
+	if nodeMeta.Addr != "" {
+		gd.updateNodeAddr(types.NodeId(nodeName), nodeMeta.Addr)
+	}
+	gd.applyNodeMaintenance(types.NodeId(nodeName), nodeMeta.Maintenance)
+}
+
+// applyNodeMaintenance records the maintenance mode published in the meta
+// data of a node. Nodes in maintenance are not a part of quorum.
+func (gd *GossipDelegate) applyNodeMaintenance(nodeId types.NodeId, maintenance bool) {
+	changed, err := gd.updateNodeMaintenance(nodeId, maintenance)
+	if err != nil || !changed {
+		return
+	}
+	if maintenance {
+		logrus.Infof("gossip: Node %v entered maintenance", nodeId)
+	} else {
+		logrus.Infof("gossip: Node %v exited maintenance", nodeId)
+	}
+	gd.triggerStateEvent(types.UPDATE_CLUSTER_SIZE)
 }
 
 func (gd *GossipDelegate) NotifyMerge(peers []*memberlist.Node) error {
@@ -295,7 +630,7 @@ func (gd *GossipDelegate) NotifyMerge(peers []*memberlist.Node) error {
 // AliveDelegate is used to involve a client in processing a node "alive" message.
 // TODO/Future-use : Check if we want to add this node in memberlist
 func (gd *GossipDelegate) NotifyAlive(node *memberlist.Node) error {
-	nodeName := gd.parseMemberlistNodeName(node.Name)
+	nodeName := gd.parseMemberlistNodeName(node)
 	if nodeName == gd.nodeId {
 		gd.triggerStateEvent(types.SELF_ALIVE)
 		return nil
@@ -310,10 +645,32 @@ func (gd *GossipDelegate) NotifyAlive(node *memberlist.Node) error {
 		// Returning a non-nil err value
 		return err
 	}
+	gd.updateNodeHeardTs(types.NodeId(nodeName))
+
+	if nodeMeta, err := gd.nodeMetaInfo(node); err == nil {
+		gd.updateNodeLeaving(types.NodeId(nodeName), nodeMeta.Leaving, nodeMeta.LeaveReason)
+		if nodeMeta.Leaving {
+			// Node is on its way out. NotifyLeave will follow.
+			return nil
+		}
+	}
 
 	diffNode, err := gd.GetLocalNodeInfo(types.NodeId(nodeName))
-	if err == nil && diffNode.Status != types.NODE_STATUS_UP {
-		gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_UP)
+	if err == nil && diffNode.Maintenance {
+		// Node in maintenance does not affect quorum
+		if diffNode.Status != types.NODE_STATUS_MAINTENANCE {
+			gd.UpdateNodeStatus(types.NodeId(nodeName), types.NODE_STATUS_MAINTENANCE)
+		}
+		return nil
+	}
+	if err == nil && diffNode.Status == types.NODE_STATUS_DAMPED {
+		// Node stays damped till it is stable. Just keep a note that
+		// memberlist sees it alive.
+		gd.setDampedNodeAlive(nodeName, true)
+		return nil
+	}
+	if err == nil && diffNode.Status != aliveStatus(diffNode) {
+		gd.UpdateNodeStatus(types.NodeId(nodeName), aliveStatus(diffNode))
 		gd.triggerStateEvent(types.NODE_ALIVE)
 		if diffNode.Status == types.NODE_STATUS_SUSPECT_DOWN {
 			// Remove the node from probation list
@@ -333,7 +690,7 @@ func (gd *GossipDelegate) probationExpiredOnSuspectedDownNode(probationID string
 	nodeName := gd.probationIDToNodeName(probationID)
 	logrus.Infof("gossip: probation time expired for suspected offline node %v ", nodeName)
 	selfStatus := gd.GetSelfStatus()
-	if selfStatus != types.NODE_STATUS_UP {
+	if selfStatus != types.NODE_STATUS_UP && selfStatus != types.NODE_STATUS_WITNESS {
 		// We are not in up and probably out of quorum
 		// Wait again before we mark this node down
 		logrus.Infof("gossip: we are suspected not in quorum, adding suspected offline node %v back to probation list", nodeName)
@@ -350,37 +707,52 @@ func (gd *GossipDelegate) probationExpiredOnSuspectedDownNode(probationID string
 }
 
 func (gd *GossipDelegate) triggerStateEvent(event types.StateEvent) {
-	gd.stateEvent <- event
+	select {
+	case gd.stateEvent <- event:
+	case <-gd.getStateStopCh():
+		// Gossip has stopped
+	}
 	return
 }
 
-func (gd *GossipDelegate) startQuorumTimer() {
-	gd.timeoutVersionLock.Lock()
-	localVersion := gd.timeoutVersion + 1
-	gd.timeoutVersion = localVersion
-	gd.timeoutVersionLock.Unlock()
-
-	logrus.Infof("gossip: Starting Quorum Timer with version v%v. Waiting for quorum timeout of (%v)", localVersion, gd.quorumTimeout)
-	time.Sleep(gd.quorumTimeout)
-
-	gd.timeoutVersionLock.Lock()
-	if localVersion == gd.timeoutVersion {
-		gd.timeoutVersionLock.Unlock()
-		gd.stateEvent <- types.TIMEOUT
-		return
-	} // else do not send an event. Another timer started
-	gd.timeoutVersionLock.Unlock()
-}
-
-func (gd *GossipDelegate) parseMemberlistNodeName(nodeName string) string {
-	return strings.TrimSuffix(nodeName, gd.GetGossipVersion())
+// parseMemberlistNodeName returns the node id from the name of a memberlist
+// node, which has the gossip version of the node as a suffix. The version is
+// taken from the meta data of the node, or else has to be a version we know.
+func (gd *GossipDelegate) parseMemberlistNodeName(node *memberlist.Node) string {
+	if nodeMeta, err := gd.nodeMetaInfo(node); err == nil &&
+		nodeMeta.GossipVersion != "" && strings.HasSuffix(node.Name, nodeMeta.GossipVersion) {
+		return strings.TrimSuffix(node.Name, nodeMeta.GossipVersion)
+	}
+	for _, version := range gd.knownGossipVersions() {
+		if strings.HasSuffix(node.Name, version) {
+			return strings.TrimSuffix(node.Name, version)
+		}
+	}
+	return node.Name
 }
 
-func (gd *GossipDelegate) handleStateEvents() {
+func (gd *GossipDelegate) handleStateEvents(stopCh chan struct{}) {
+	defer gd.routines.Done()
 	for {
 		// We block here until we get an event
-		event := <-gd.stateEvent
+		var event types.StateEvent
+		select {
+		case event = <-gd.stateEvent:
+		case <-gd.quorumTimer.C():
+			gd.quorumTimer.stop()
+			event = types.TIMEOUT
+		case <-gd.recoveryTimer.C():
+			gd.recoveryTimer.stop()
+			event = types.QUORUM_STABLE
+		case <-stopCh:
+			gd.quorumTimer.stop()
+			gd.recoveryTimer.stop()
+			return
+		}
+		gd.updateWitnessVote()
+		previousState := gd.currentState
 		previousStatus := gd.currentState.NodeStatus()
+		previousSelfStatus := gd.GetSelfStatus()
 		switch event {
 		case types.SELF_ALIVE:
 			gd.currentState, _ = gd.currentState.SelfAlive(gd.GetLocalState())
@@ -394,6 +766,10 @@ func (gd *GossipDelegate) handleStateEvents() {
 			gd.currentState, _ = gd.currentState.UpdateClusterSize(gd.GetLocalState())
 		case types.UPDATE_CLUSTER_DOMAINS_ACTIVE_MAP:
 			gd.currentState, _ = gd.currentState.UpdateClusterDomainsActiveMap(gd.GetLocalState())
+		case types.ENTER_MAINTENANCE:
+			gd.currentState, _ = gd.currentState.EnterMaintenance()
+		case types.EXIT_MAINTENANCE:
+			gd.currentState, _ = gd.currentState.ExitMaintenance(gd.GetLocalState())
 		case types.TIMEOUT:
 			newState, _ := gd.currentState.Timeout(gd.GetLocalState())
 			if newState.NodeStatus() != gd.currentState.NodeStatus() {
@@ -401,15 +777,145 @@ func (gd *GossipDelegate) handleStateEvents() {
 					gd.quorumTimeout)
 			}
 			gd.currentState = newState
+		case types.QUORUM_STABLE:
+			gd.currentState, _ = gd.currentState.UpdateClusterSize(gd.GetLocalState())
 		}
+		gd.currentState = gd.holdRecovery(event, previousState, gd.currentState)
 		newStatus := gd.currentState.NodeStatus()
-		if previousStatus == types.NODE_STATUS_UP &&
-			newStatus == types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
-			// Start a timer
-			go gd.startQuorumTimer()
+		if newStatus != types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
+			gd.quorumTimer.stop()
+		} else if previousStatus == types.NODE_STATUS_UP {
+			logrus.Infof("gossip: Starting Quorum Timer. Waiting for quorum timeout of (%v)", gd.quorumTimeout)
+			gd.quorumTimer.start(gd.quorumTimeout)
+		}
+		if newStatus == types.NODE_STATUS_UP {
+			gd.wasUp = true
+		}
+		newSelfStatus := gd.selfStatus()
+		gd.UpdateSelfStatus(newSelfStatus)
+		if newSelfStatus != previousSelfStatus {
+			gd.recordStateTransition(event, previousSelfStatus, newSelfStatus)
+			gd.fenceOnStatusChange(newSelfStatus)
+		}
+	}
+}
+
+// holdRecovery keeps a node which lost quorum in NOT_IN_QUORUM till quorum
+// holds for the recovery interval. The recovery timer is cancelled if quorum
+// is lost again in the meantime.
+func (gd *GossipDelegate) holdRecovery(
+	event types.StateEvent,
+	previousState state.State,
+	nextState state.State,
+) state.State {
+	if previousState.NodeStatus() != types.NODE_STATUS_NOT_IN_QUORUM ||
+		gd.quorumRecoveryInterval == 0 || !gd.wasUp {
+		gd.recoveryTimer.stop()
+		return nextState
+	}
+	if nextState.NodeStatus() != types.NODE_STATUS_UP {
+		// Not every event evaluates quorum, so check if it still holds
+		if gd.recoveryTimer.running() &&
+			(nextState.NodeStatus() != types.NODE_STATUS_NOT_IN_QUORUM ||
+				!gd.quorumProvider.IsNodeInQuorum(gd.GetLocalState())) {
+			logrus.Infof("gossip: Quorum lost again before it was stable for (%v)",
+				gd.quorumRecoveryInterval)
+			gd.recoveryTimer.stop()
+		}
+		return nextState
+	}
+	if event == types.QUORUM_STABLE {
+		logrus.Infof("gossip: Quorum was stable for (%v)", gd.quorumRecoveryInterval)
+		return nextState
+	}
+	if !gd.recoveryTimer.running() {
+		logrus.Infof("gossip: Quorum regained. Waiting for it to be stable for (%v)",
+			gd.quorumRecoveryInterval)
+		gd.recoveryTimer.start(gd.quorumRecoveryInterval)
+	}
+	return previousState
+}
+
+// selfStatus returns the status of this node as per its current state. A
+// witness which is in quorum reports the witness status instead of up.
+func (gd *GossipDelegate) selfStatus() types.NodeStatus {
+	status := gd.currentState.NodeStatus()
+	if status != types.NODE_STATUS_UP {
+		return status
+	}
+	selfNodeInfo, err := gd.GetLocalNodeInfo(gd.NodeId())
+	if err != nil {
+		return status
+	}
+	return aliveStatus(selfNodeInfo)
+}
+
+// updateWitnessVote moves the vote of this node to another quorum member
+// if this node is a witness which can no more see the member it votes for
+func (gd *GossipDelegate) updateWitnessVote() {
+	selfNodeInfo, err := gd.GetLocalNodeInfo(gd.NodeId())
+	if err != nil || !selfNodeInfo.Witness {
+		return
+	}
+	vote := gd.chooseWitnessVote(gd.GetLocalState(), selfNodeInfo.WitnessVote)
+	if vote != selfNodeInfo.WitnessVote && gd.updateSelfWitnessVote(vote) {
+		logrus.Infof("gossip: Witness vote moved from node %v to node %v",
+			selfNodeInfo.WitnessVote, vote)
+	}
+}
+
+// chooseWitnessVote returns the quorum member to which a witness gives its
+// vote. The vote is sticky: the witness keeps voting for the current member
+// as long as it can see it, so that the vote is never counted on both sides
+// of a partition which the witness can still reach.
+func (gd *GossipDelegate) chooseWitnessVote(
+	localNodeInfoMap types.NodeInfoMap,
+	current types.NodeId,
+) types.NodeId {
+	canVoteFor := func(nodeInfo types.NodeInfo) bool {
+		return nodeInfo.QuorumMember && !nodeInfo.Witness && !nodeInfo.Maintenance &&
+			nodeInfo.Status == types.NODE_STATUS_UP &&
+			gd.quorumProvider.IsDomainActive(nodeInfo.ClusterDomain)
+	}
+	if nodeInfo, ok := localNodeInfoMap[current]; ok && canVoteFor(nodeInfo) {
+		return current
+	}
+	ids := make([]string, 0, len(localNodeInfoMap))
+	for id, nodeInfo := range localNodeInfoMap {
+		if canVoteFor(nodeInfo) {
+			ids = append(ids, string(id))
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	return types.NodeId(ids[0])
+}
+
+// explainQuorum explains the verdict of the quorum provider for the given
+// state of the cluster
+func (gd *GossipDelegate) explainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
+	selfStatus := gd.GetSelfStatus()
+	quorumProvider := gd.quorumProvider
+	if quorumProvider == nil {
+		return types.QuorumExplanation{
+			Status: selfStatus,
+			Reason: "gossip not started yet",
+		}
+	}
+	explainer, ok := quorumProvider.(state.QuorumExplainer)
+	if !ok {
+		return types.QuorumExplanation{
+			Provider: quorumProvider.Type(),
+			Status:   selfStatus,
+			InQuorum: selfStatus == types.NODE_STATUS_UP || selfStatus == types.NODE_STATUS_WITNESS,
+			Reason:   "quorum provider does not explain its verdict",
 		}
-		gd.UpdateSelfStatus(gd.currentState.NodeStatus())
 	}
+	explanation := explainer.ExplainQuorum(localNodeInfoMap)
+	explanation.Status = selfStatus
+	return explanation
 }
 
 func (gd *GossipDelegate) nodeNameToProbationID(nodeName string) string {
@@ -0,0 +1,415 @@
+package proto
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/proto/state"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGossipDelegate returns a gossip delegate which is not backed by
+// memberlist. Memberlist events can be simulated by calling the delegate
+// callbacks with nodes returned by newTestMemberlistNode
+func newTestGossipDelegate(
+	selfNodeId types.NodeId,
+	peers map[types.NodeId]types.NodeUpdate,
+	quorumProviderType types.QuorumProvider,
+) *GossipDelegate {
+	return newTestGossipDelegateWithTimeouts(selfNodeId, peers, quorumProviderType, TestQuorumTimeout, 0)
+}
+
+func newTestGossipDelegateWithTimeouts(
+	selfNodeId types.NodeId,
+	peers map[types.NodeId]types.NodeUpdate,
+	quorumProviderType types.QuorumProvider,
+	quorumTimeout time.Duration,
+	quorumRecoveryInterval time.Duration,
+) *GossipDelegate {
+	gd := &GossipDelegate{}
+	gd.InitGossipDelegate(
+		1,
+		selfNodeId,
+		types.GOSSIP_VERSION_2,
+		quorumTimeout,
+		DEFAULT_CLUSTER_ID,
+		"",
+		func(types.NodeId, string) (time.Duration, error) {
+			return 0, nil
+		},
+	)
+	gd.quorumRecoveryInterval = quorumRecoveryInterval
+	quorumProvider := state.NewQuorumProvider(selfNodeId, quorumProviderType)
+	quorumProvider.UpdateNumOfQuorumMembers(gd.updateCluster(peers))
+	gd.InitCurrentState(uint(len(peers)), quorumProvider)
+	return gd
+}
+
+func newTestMemberlistNode(t *testing.T, nodeId types.NodeId) *memberlist.Node {
+	gs := NewGossipStore(nodeId, types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	return newTestMemberlistNodeWithMeta(t, nodeId, gs.MetaInfo())
+}
+
+func newTestMemberlistNodeWithMeta(
+	t *testing.T,
+	nodeId types.NodeId,
+	metaInfo types.NodeMetaInfo,
+) *memberlist.Node {
+	gs := NewGossipStore(nodeId, types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	meta, err := gs.convertToBytes(metaInfo)
+	require.NoError(t, err, "Failed to convert meta info")
+	return &memberlist.Node{
+		Name: string(nodeId) + types.GOSSIP_VERSION_2,
+		Meta: meta,
+	}
+}
+
+func getTestPeers(numNodes int) map[types.NodeId]types.NodeUpdate {
+	peers := make(map[types.NodeId]types.NodeUpdate)
+	for i := 0; i < numNodes; i++ {
+		peers[types.NodeId(string(rune('0'+i)))] = types.NodeUpdate{
+			QuorumMember: true,
+		}
+	}
+	return peers
+}
+
+func getNodeStatus(t *testing.T, gd *GossipDelegate, nodeId types.NodeId) types.NodeStatus {
+	nodeInfo, err := gd.GetLocalNodeInfo(nodeId)
+	require.NoError(t, err, "Failed to get node info")
+	return nodeInfo.Status
+}
+
+// testClock is a clock which is advanced by the tests
+type testClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestGossipDelegateFlapDamping(t *testing.T) {
+	printTestInfo()
+
+	clock := &testClock{now: time.Now()}
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	gd.flapDampingClock = clock.Now
+	require.NoError(t, gd.InitFlapDamping(types.FlapDampingConfig{
+		Penalty:           types.DEFAULT_FLAP_PENALTY,
+		SuppressThreshold: 1.5 * types.DEFAULT_FLAP_PENALTY,
+		ReuseThreshold:    types.DEFAULT_FLAP_REUSE_THRESHOLD,
+		MaxPenalty:        types.DEFAULT_FLAP_MAX_PENALTY,
+		HalfLife:          time.Minute,
+	}))
+	defer gd.flapDampingManager.Stop()
+
+	node := newTestMemberlistNode(t, "1")
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+
+	// First flap marks the node down
+	gd.NotifyLeave(node)
+	require.Equal(t, types.NODE_STATUS_DOWN, getNodeStatus(t, gd, "1"))
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+
+	// Second flap damps the node
+	gd.NotifyLeave(node)
+	require.Equal(t, types.NODE_STATUS_DAMPED, getNodeStatus(t, gd, "1"))
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	require.Equal(t, types.NODE_STATUS_DAMPED, getNodeStatus(t, gd, "1"))
+
+	// Node is released once it stays stable
+	clock.Advance(5 * time.Minute)
+	for i := 0; i < 50 && getNodeStatus(t, gd, "1") != types.NODE_STATUS_UP; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+}
+
+func TestGossipDelegateFlapDampingDefaults(t *testing.T) {
+	printTestInfo()
+
+	// The fields which are not set get the defaults
+	config, err := flapDampingConfigWithDefaults(types.FlapDampingConfig{
+		Penalty: types.DEFAULT_FLAP_PENALTY,
+	})
+	require.NoError(t, err, "Unexpected error for a partial config")
+	require.Equal(t, types.FlapDampingConfig{
+		Penalty:           types.DEFAULT_FLAP_PENALTY,
+		SuppressThreshold: types.DEFAULT_FLAP_SUPPRESS_THRESHOLD,
+		ReuseThreshold:    types.DEFAULT_FLAP_REUSE_THRESHOLD,
+		MaxPenalty:        types.DEFAULT_FLAP_MAX_PENALTY,
+		HalfLife:          types.DEFAULT_FLAP_HALF_LIFE,
+	}, config)
+
+	_, err = flapDampingConfigWithDefaults(types.FlapDampingConfig{
+		Penalty:           types.DEFAULT_FLAP_PENALTY,
+		SuppressThreshold: 1000,
+		ReuseThreshold:    1000,
+	})
+	require.Error(t, err, "Expected an error for a reuse threshold above the suppress threshold")
+
+	// With only the penalty set, the first flap does not damp the node
+	clock := &testClock{now: time.Now()}
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	gd.flapDampingClock = clock.Now
+	require.NoError(t, gd.InitFlapDamping(types.FlapDampingConfig{
+		Penalty: types.DEFAULT_FLAP_PENALTY,
+	}))
+	defer gd.flapDampingManager.Stop()
+	node := newTestMemberlistNode(t, "1")
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	gd.NotifyLeave(node)
+	require.Equal(t, types.NODE_STATUS_DOWN, getNodeStatus(t, gd, "1"))
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+
+	// The penalty decays, so the node is not damped forever
+	gd.NotifyLeave(node)
+	require.Equal(t, types.NODE_STATUS_DAMPED, getNodeStatus(t, gd, "1"))
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	clock.Advance(5 * types.DEFAULT_FLAP_HALF_LIFE)
+	for i := 0; i < 50 && getNodeStatus(t, gd, "1") != types.NODE_STATUS_UP; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+}
+
+func waitForSelfStatus(t *testing.T, gd *GossipDelegate, status types.NodeStatus) {
+	for i := 0; i < 50; i++ {
+		if gd.GetSelfStatus() == status {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, status, gd.GetSelfStatus(), "Unexpected self status")
+}
+
+func TestGossipDelegateMaintenance(t *testing.T) {
+	printTestInfo()
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	nodes := []*memberlist.Node{
+		newTestMemberlistNode(t, "1"),
+		newTestMemberlistNode(t, "2"),
+	}
+	gd.NotifyAlive(newTestMemberlistNode(t, "0"))
+	for _, node := range nodes {
+		require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	}
+	waitForSelfStatus(t, gd, types.NODE_STATUS_UP)
+
+	// Peer nodes 1 and 2 enter maintenance
+	remoteState := gd.GetLocalState()
+	for _, id := range []types.NodeId{"1", "2"} {
+		nodeInfo := remoteState[id]
+		nodeInfo.Maintenance = true
+		nodeInfo.LastUpdateTs = time.Now()
+		remoteState[id] = nodeInfo
+	}
+	buf, err := gd.convertToBytes(remoteState)
+	require.NoError(t, err, "Failed to convert remote state")
+	gd.MergeRemoteState(buf, false)
+	require.Equal(t, types.NODE_STATUS_MAINTENANCE, getNodeStatus(t, gd, "1"))
+	require.Equal(t, types.NODE_STATUS_MAINTENANCE, getNodeStatus(t, gd, "2"))
+
+	// Nodes in maintenance leaving does not affect our quorum
+	for _, node := range nodes {
+		gd.NotifyLeave(node)
+	}
+	require.Equal(t, types.NODE_STATUS_DOWN, getNodeStatus(t, gd, "1"))
+	gd.triggerStateEvent(types.UPDATE_CLUSTER_SIZE)
+	waitForSelfStatus(t, gd, types.NODE_STATUS_UP)
+
+	// Self enters and exits maintenance
+	gd.updateSelfMaintenance(true)
+	gd.triggerStateEvent(types.ENTER_MAINTENANCE)
+	waitForSelfStatus(t, gd, types.NODE_STATUS_MAINTENANCE)
+	gd.updateSelfMaintenance(false)
+	gd.triggerStateEvent(types.EXIT_MAINTENANCE)
+	waitForSelfStatus(t, gd, types.NODE_STATUS_UP)
+}
+
+func TestGossipDelegateQuorumRecovery(t *testing.T) {
+	printTestInfo()
+
+	recoveryInterval := 2 * time.Second
+	gd := newTestGossipDelegateWithTimeouts("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT,
+		500*time.Millisecond, recoveryInterval)
+	nodes := []*memberlist.Node{
+		newTestMemberlistNode(t, "1"),
+		newTestMemberlistNode(t, "2"),
+	}
+	// No recovery interval is needed before the node is up for the first time
+	gd.NotifyAlive(newTestMemberlistNode(t, "0"))
+	for _, node := range nodes {
+		require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	}
+	waitForSelfStatus(t, gd, types.NODE_STATUS_UP)
+
+	// Node loses quorum
+	for _, node := range nodes {
+		gd.NotifyLeave(node)
+	}
+	waitForSelfStatus(t, gd, types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM)
+	waitForSelfStatus(t, gd, types.NODE_STATUS_NOT_IN_QUORUM)
+
+	// Quorum is regained but lost again before it is stable
+	require.NoError(t, gd.NotifyAlive(nodes[0]), "Unexpected error on NotifyAlive")
+	time.Sleep(recoveryInterval / 2)
+	require.Equal(t, types.NODE_STATUS_NOT_IN_QUORUM, gd.GetSelfStatus())
+	gd.NotifyLeave(nodes[0])
+	time.Sleep(recoveryInterval)
+	require.Equal(t, types.NODE_STATUS_NOT_IN_QUORUM, gd.GetSelfStatus())
+
+	// Quorum holds for the recovery interval
+	require.NoError(t, gd.NotifyAlive(nodes[0]), "Unexpected error on NotifyAlive")
+	start := time.Now()
+	waitForSelfStatus(t, gd, types.NODE_STATUS_UP)
+	require.True(t, time.Since(start) >= recoveryInterval/2,
+		"Node moved to up before quorum was stable")
+}
+
+func TestGossipDelegateGracefulLeave(t *testing.T) {
+	printTestInfo()
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	node := newTestMemberlistNode(t, "1")
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	require.Equal(t, types.NODE_STATUS_UP, getNodeStatus(t, gd, "1"))
+
+	// Node announces that it is leaving
+	gs := NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateNodeLeaving("1", true, "upgrade")
+	leavingNode := newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo())
+	require.NoError(t, gd.NotifyAlive(leavingNode), "Unexpected error on NotifyAlive")
+	gd.NotifyUpdate(leavingNode)
+	gd.NotifyLeave(leavingNode)
+
+	nodeInfo, err := gd.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, types.NODE_STATUS_LEFT, nodeInfo.Status)
+	require.Equal(t, "upgrade", nodeInfo.LeaveReason)
+
+	// A node which did not announce its leave is down
+	otherNode := newTestMemberlistNode(t, "2")
+	require.NoError(t, gd.NotifyAlive(otherNode), "Unexpected error on NotifyAlive")
+	gd.NotifyLeave(otherNode)
+	require.Equal(t, types.NODE_STATUS_DOWN, getNodeStatus(t, gd, "2"))
+
+	// Node comes back
+	require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	nodeInfo, err = gd.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, types.NODE_STATUS_UP, nodeInfo.Status)
+	require.False(t, nodeInfo.Leaving, "Expected node not leaving")
+}
+
+func TestGossipDelegateLabels(t *testing.T) {
+	printTestInfo()
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	gd.updateSelfLabels(map[string]string{"rack": "r1", "role": "storage"})
+
+	gs := NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateSelfLabels(map[string]string{"rack": "r1", "role": "compute"})
+	gd.NotifyJoin(newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo()))
+
+	gs = NewGossipStore("2", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gd.NotifyJoin(newTestMemberlistNodeWithMeta(t, "2", gs.MetaInfo()))
+	require.Empty(t, gd.GetNodesByLabel(map[string]string{"rack": "r2"}))
+
+	// Labels are updated through the meta data
+	gs.updateSelfLabels(map[string]string{"rack": "r2", "role": "storage"})
+	gd.NotifyUpdate(newTestMemberlistNodeWithMeta(t, "2", gs.MetaInfo()))
+
+	require.Equal(t, []types.NodeId{"0", "1"}, gd.GetNodesByLabel(map[string]string{"rack": "r1"}))
+	require.Equal(t, []types.NodeId{"0", "2"}, gd.GetNodesByLabel(map[string]string{"role": "storage"}))
+	require.Equal(t, []types.NodeId{"2"},
+		gd.GetNodesByLabel(map[string]string{"rack": "r2", "role": "storage"}))
+	require.Equal(t, []types.NodeId{"0", "1", "2"}, gd.GetNodesByLabel(nil))
+
+	// Stale labels from other nodes do not override the meta data
+	staleNodeInfo, err := gd.GetLocalNodeInfo("2")
+	require.NoError(t, err, "Failed to get node info")
+	staleNodeInfo.Labels = map[string]string{"rack": "r1"}
+	staleNodeInfo.LabelsTs = staleNodeInfo.LabelsTs.Add(-time.Second)
+	staleNodeInfo.LastUpdateTs = time.Now()
+	gd.Update(types.NodeInfoMap{"2": staleNodeInfo})
+	require.Equal(t, []types.NodeId{"0", "1"}, gd.GetNodesByLabel(map[string]string{"rack": "r1"}))
+
+	// Labels removed through the meta data are not restored by the stale
+	// labels of other nodes
+	gs.updateSelfLabels(nil)
+	gd.NotifyUpdate(newTestMemberlistNodeWithMeta(t, "2", gs.MetaInfo()))
+	staleNodeInfo.LastUpdateTs = time.Now()
+	gd.Update(types.NodeInfoMap{"2": staleNodeInfo})
+	require.Equal(t, []types.NodeId{"0", "1"}, gd.GetNodesByLabel(map[string]string{"rack": "r1"}))
+	require.Empty(t, gd.GetNodesByLabel(map[string]string{"rack": "r2"}))
+
+	// Labels gossiped by other nodes replace older meta data
+	newNodeInfo, err := gd.GetLocalNodeInfo("2")
+	require.NoError(t, err, "Failed to get node info")
+	newNodeInfo.Labels = map[string]string{"rack": "r3"}
+	newNodeInfo.LabelsTs = time.Now()
+	newNodeInfo.LastUpdateTs = time.Now()
+	gd.Update(types.NodeInfoMap{"2": newNodeInfo})
+	require.Equal(t, []types.NodeId{"2"}, gd.GetNodesByLabel(map[string]string{"rack": "r3"}))
+
+	// Labels are dropped from meta data over the limit
+	require.Equal(t, "r1", gd.MetaInfo().Labels["rack"])
+	var nodeMeta types.NodeMetaInfo
+	require.NoError(t, gd.convertFromBytes(gd.NodeMeta(16), &nodeMeta), "Failed to decode meta")
+	require.Empty(t, nodeMeta.Labels)
+}
+
+func TestGossipDelegateWitnessVote(t *testing.T) {
+	printTestInfo()
+
+	peers := getTestPeers(2)
+	peers["2"] = types.NodeUpdate{Witness: true}
+	gd := newTestGossipDelegate("2", peers, types.QUORUM_PROVIDER_DEFAULT)
+	waitForWitnessVote := func(vote types.NodeId) {
+		for i := 0; i < 50; i++ {
+			if nodeInfo, _ := gd.GetLocalNodeInfo("2"); nodeInfo.WitnessVote == vote {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		nodeInfo, _ := gd.GetLocalNodeInfo("2")
+		require.Equal(t, vote, nodeInfo.WitnessVote, "Unexpected witness vote")
+	}
+	nodes := []*memberlist.Node{
+		newTestMemberlistNode(t, "0"),
+		newTestMemberlistNode(t, "1"),
+	}
+	gd.NotifyAlive(newTestMemberlistNode(t, "2"))
+	for _, node := range nodes {
+		require.NoError(t, gd.NotifyAlive(node), "Unexpected error on NotifyAlive")
+	}
+	waitForSelfStatus(t, gd, types.NODE_STATUS_WITNESS)
+	waitForWitnessVote("0")
+
+	// The vote moves once the witness cannot see the node it votes for
+	gd.NotifyLeave(nodes[0])
+	waitForWitnessVote("1")
+
+	// The vote is sticky and does not move back
+	require.NoError(t, gd.NotifyAlive(nodes[0]), "Unexpected error on NotifyAlive")
+	waitForSelfStatus(t, gd, types.NODE_STATUS_WITNESS)
+	waitForWitnessVote("1")
+}
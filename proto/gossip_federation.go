@@ -0,0 +1,198 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	ml "github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// federation is the gossip pool of the gateway nodes of multiple clusters.
+// The gateways exchange the summaries of their clusters on push/pull.
+type federation struct {
+	sync.Mutex
+	clusterId string
+	mlist     *ml.Memberlist
+	// summarize returns the summary of our cluster
+	summarize func() types.ClusterSummary
+	// summaries are the latest summaries of the other clusters
+	summaries map[string]types.ClusterSummary
+}
+
+func newFederation(clusterId string, summarize func() types.ClusterSummary) *federation {
+	return &federation{
+		clusterId: clusterId,
+		summarize: summarize,
+		summaries: make(map[string]types.ClusterSummary),
+	}
+}
+
+// NodeMeta is not used by the federation
+func (f *federation) NodeMeta(limit int) []byte {
+	return []byte{}
+}
+
+// NotifyMsg is not used by the federation
+func (f *federation) NotifyMsg(data []byte) {}
+
+// GetBroadcasts is not used by the federation
+func (f *federation) GetBroadcasts(overhead, limit int) [][]byte {
+	return nil
+}
+
+// LocalState returns the summary of our cluster along with the summaries
+// of the other clusters we know of
+func (f *federation) LocalState(join bool) []byte {
+	summaries := f.getSummaries()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(summaries); err != nil {
+		log.Warnf("gossip: Unable to encode cluster summaries: %v", err)
+		return []byte{}
+	}
+	return buf.Bytes()
+}
+
+// MergeRemoteState keeps the latest summary of every other cluster
+func (f *federation) MergeRemoteState(buf []byte, join bool) {
+	if len(buf) == 0 {
+		return
+	}
+	var summaries map[string]types.ClusterSummary
+	if err := gob.NewDecoder(bytes.NewBuffer(buf)).Decode(&summaries); err != nil {
+		log.Warnf("gossip: Unable to decode cluster summaries: %v", err)
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	for clusterId, summary := range summaries {
+		if clusterId == f.clusterId {
+			continue
+		}
+		if current, ok := f.summaries[clusterId]; ok && !summary.LastUpdateTs.After(current.LastUpdateTs) {
+			continue
+		}
+		f.summaries[clusterId] = summary
+	}
+}
+
+// getSummaries returns the summaries of all the clusters including ours
+func (f *federation) getSummaries() map[string]types.ClusterSummary {
+	f.Lock()
+	summaries := make(map[string]types.ClusterSummary, len(f.summaries)+1)
+	for clusterId, summary := range f.summaries {
+		summaries[clusterId] = summary
+	}
+	f.Unlock()
+	summaries[f.clusterId] = f.summarize()
+	return summaries
+}
+
+// start creates the memberlist of the federation and joins the gateways of
+// the other clusters
+func (f *federation) start(nodeId types.NodeId, config *types.FederationConfig) error {
+	host, port, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return fmt.Errorf("gossip: Invalid federation address %v: %v", config.Addr, err)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("gossip: Invalid federation address %v: %v", config.Addr, err)
+	}
+
+	mlConf := ml.DefaultWANConfig()
+	// Node ids are only unique within a cluster
+	mlConf.Name = f.clusterId + "/" + string(nodeId)
+	mlConf.BindAddr = host
+	mlConf.BindPort = int(portNum)
+	if config.PushPullInterval != 0 {
+		mlConf.PushPullInterval = config.PushPullInterval
+	}
+	mlConf.Delegate = ml.Delegate(f)
+
+	list, err := ml.Create(mlConf)
+	if err != nil {
+		return fmt.Errorf("gossip: Unable to create federation memberlist: %v", err)
+	}
+	f.mlist = list
+	if len(config.Gateways) > 0 {
+		if joined, err := list.Join(config.Gateways); err != nil {
+			// The other gateways join us when they come up
+			log.Infof("gossip: Unable to join other cluster gateways: %v", err)
+		} else {
+			log.Infof("gossip: Joined %v cluster gateway(s)", joined)
+		}
+	}
+	return nil
+}
+
+func (f *federation) stop(leaveTimeout time.Duration) {
+	if leaveTimeout != 0 {
+		if err := f.mlist.Leave(leaveTimeout); err != nil {
+			log.Warnf("gossip: Unable to leave the federation: %v", err)
+		}
+	}
+	if err := f.mlist.Shutdown(); err != nil {
+		log.Warnf("gossip: Unable to shutdown federation memberlist: %v", err)
+	}
+}
+
+// startFederation makes this node a gateway of the cluster in the federation
+func (g *GossiperImpl) startFederation(config *types.FederationConfig) error {
+	exportedKeys := append([]types.StoreKey{}, config.ExportedKeys...)
+	f := newFederation(g.GetClusterId(), func() types.ClusterSummary {
+		return g.summarizeCluster(exportedKeys)
+	})
+	if err := f.start(g.selfNodeId, config); err != nil {
+		return err
+	}
+	log.Infof("gossip: Gateway of cluster %v in the federation at %v", g.GetClusterId(), config.Addr)
+	g.federation = f
+	return nil
+}
+
+func (g *GossiperImpl) stopFederation(leaveTimeout time.Duration) {
+	if g.federation != nil {
+		g.federation.stop(leaveTimeout)
+		g.federation = nil
+	}
+}
+
+// summarizeCluster returns the summary of our cluster as seen by this node
+func (g *GossiperImpl) summarizeCluster(exportedKeys []types.StoreKey) types.ClusterSummary {
+	summary := types.ClusterSummary{
+		ClusterId:     g.GetClusterId(),
+		GatewayId:     g.selfNodeId,
+		GatewayStatus: g.GetSelfStatus(),
+		Values:        make(map[types.StoreKey]types.NodeValueMap),
+		LastUpdateTs:  time.Now(),
+	}
+	for _, nodeInfo := range g.GetLocalState() {
+		summary.NumNodes++
+		if nodeInfo.Status == types.NODE_STATUS_UP {
+			summary.NumNodesUp++
+		}
+	}
+	for _, key := range exportedKeys {
+		summary.Values[key] = g.GetStoreKeyValue(key)
+	}
+	return summary
+}
+
+func (g *GossiperImpl) GetFederatedClusters() map[string]types.ClusterSummary {
+	g.joinLock.Lock()
+	f := g.federation
+	g.joinLock.Unlock()
+	if f == nil {
+		return map[string]types.ClusterSummary{
+			g.GetClusterId(): g.summarizeCluster(nil),
+		}
+	}
+	return f.getSummaries()
+}
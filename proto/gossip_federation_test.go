@@ -0,0 +1,107 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederationMergeRemoteState(t *testing.T) {
+	printTestInfo()
+
+	now := time.Now()
+	newSummarize := func(clusterId string, numNodes int) func() types.ClusterSummary {
+		return func() types.ClusterSummary {
+			return types.ClusterSummary{ClusterId: clusterId, NumNodes: numNodes, LastUpdateTs: time.Now()}
+		}
+	}
+	f1 := newFederation("cluster1", newSummarize("cluster1", 3))
+	f2 := newFederation("cluster2", newSummarize("cluster2", 5))
+	f2.summaries["cluster3"] = types.ClusterSummary{ClusterId: "cluster3", NumNodes: 1, LastUpdateTs: now}
+	// Summary of our own cluster from another gateway is ignored
+	f2.summaries["cluster1"] = types.ClusterSummary{ClusterId: "cluster1", NumNodes: 10, LastUpdateTs: now}
+
+	f1.MergeRemoteState(f2.LocalState(false), false)
+	summaries := f1.getSummaries()
+	require.Len(t, summaries, 3)
+	require.Equal(t, 3, summaries["cluster1"].NumNodes)
+	require.Equal(t, 5, summaries["cluster2"].NumNodes)
+	require.Equal(t, 1, summaries["cluster3"].NumNodes)
+
+	// Older summaries do not replace newer ones
+	f3 := newFederation("cluster4", newSummarize("cluster4", 1))
+	f3.summaries["cluster3"] = types.ClusterSummary{
+		ClusterId:    "cluster3",
+		NumNodes:     2,
+		LastUpdateTs: now.Add(-time.Minute),
+	}
+	f1.MergeRemoteState(f3.LocalState(false), false)
+	require.Equal(t, 1, f1.getSummaries()["cluster3"].NumNodes)
+}
+
+func TestGossiperFederation(t *testing.T) {
+	printTestInfo()
+
+	newGateway := func(ip, clusterId string, config *types.FederationConfig) *GossiperImpl {
+		g := new(GossiperImpl)
+		gi := types.GossipIntervals{
+			GossipInterval:   types.DEFAULT_GOSSIP_INTERVAL,
+			PushPullInterval: types.DEFAULT_PUSH_PULL_INTERVAL,
+			ProbeInterval:    types.DEFAULT_PROBE_INTERVAL,
+			ProbeTimeout:     types.DEFAULT_PROBE_TIMEOUT,
+			QuorumTimeout:    TestQuorumTimeout,
+			SuspicionMult:    types.DEFAULT_SUSPICION_MULTIPLIER,
+		}
+		g.Init(ip, "0", 1, gi, types.GOSSIP_VERSION_2, clusterId, "")
+		g.selfCorrect = false
+		err := g.Start(types.GossipStartConfiguration{
+			QuorumProviderType: types.QUORUM_PROVIDER_DEFAULT,
+			Federation:         config,
+		})
+		require.NoError(t, err, "Failed to start gateway of %v", clusterId)
+		return g
+	}
+
+	g1 := newGateway("127.0.0.1:9964", "cluster1", &types.FederationConfig{
+		Addr:             "127.0.0.1:9966",
+		ExportedKeys:     []types.StoreKey{"health"},
+		PushPullInterval: 500 * time.Millisecond,
+	})
+	g1.UpdateSelf("health", "green")
+	g1.UpdateSelf("private", "secret")
+	g2 := newGateway("127.0.0.2:9965", "cluster2", &types.FederationConfig{
+		Addr:             "127.0.0.2:9967",
+		Gateways:         []string{"127.0.0.1:9966"},
+		PushPullInterval: 500 * time.Millisecond,
+	})
+
+	// The join exchanges the summaries
+	summaries := g2.GetFederatedClusters()
+	require.Len(t, summaries, 2)
+	summary := summaries["cluster1"]
+	require.Equal(t, types.NodeId("0"), summary.GatewayId)
+	require.Equal(t, 1, summary.NumNodes)
+	require.Equal(t, "green", summary.Values["health"]["0"].Value)
+	require.NotContains(t, summary.Values, types.StoreKey("private"))
+	require.Len(t, g1.GetFederatedClusters(), 2)
+
+	// Summaries are refreshed on push/pull
+	g1.UpdateSelf("health", "red")
+	for i := 0; i < 30; i++ {
+		if g2.GetFederatedClusters()["cluster1"].Values["health"]["0"].Value == "red" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, "red", g2.GetFederatedClusters()["cluster1"].Values["health"]["0"].Value)
+
+	// Nodes which are not gateways only see their own cluster
+	g3 := newGateway("127.0.0.3:9968", "cluster3", nil)
+	require.Len(t, g3.GetFederatedClusters(), 1)
+
+	require.NoError(t, g1.Stop(time.Second))
+	require.NoError(t, g2.Stop(time.Second))
+	require.NoError(t, g3.Stop(time.Second))
+}
@@ -0,0 +1,199 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/sirupsen/logrus"
+)
+
+// fenceRequest is a fencing action queued for the hooks
+type fenceRequest struct {
+	action types.FenceAction
+	status types.NodeStatus
+}
+
+// fencer runs the fencing hooks, in the order they were registered, when
+// this node drops out of quorum and when it regains quorum. The requests
+// are run one after the other in the background, so that slow hooks do not
+// hold up the state machine. A hook which misses its deadline is waited for
+// before the next hook runs, so that an unfence never overlaps a fence.
+type fencer struct {
+	lock  sync.Mutex
+	hooks []types.FenceHook
+	// armed is true once this node is up after gossip started. A node is
+	// not fenced before that, as it starts out of quorum.
+	armed bool
+	// fenced is true if the last queued action fences this node
+	fenced  bool
+	pending []fenceRequest
+	wakeup  chan struct{}
+	// onTimeout is an optional callback invoked when a hook misses its
+	// deadline
+	onTimeout func(hook string, action types.FenceAction)
+	// hookRoutines tracks the hooks still running. The hooks are cancelled
+	// and waited for when gossip is stopped.
+	hookRoutines sync.WaitGroup
+}
+
+func (f *fencer) getWakeup() chan struct{} {
+	if f.wakeup == nil {
+		f.wakeup = make(chan struct{}, 1)
+	}
+	return f.wakeup
+}
+
+func (f *fencer) register(hook types.FenceHook) error {
+	if hook.Name == "" || hook.Fn == nil {
+		return fmt.Errorf("gossip: Fence hook needs a name and a function")
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, h := range f.hooks {
+		if h.Name == hook.Name {
+			return fmt.Errorf("gossip: Fence hook %v is already registered", hook.Name)
+		}
+	}
+	if hook.Deadline == 0 {
+		hook.Deadline = types.DEFAULT_FENCE_HOOK_DEADLINE
+	}
+	f.hooks = append(f.hooks, hook)
+	return nil
+}
+
+func (f *fencer) unregister(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i, h := range f.hooks {
+		if h.Name == name {
+			f.hooks = append(f.hooks[:i], f.hooks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("gossip: Fence hook %v is not registered", name)
+}
+
+// request queues the action unless it was the last one queued
+func (f *fencer) request(action types.FenceAction, status types.NodeStatus) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	fence := action == types.FENCE_ACTION_FENCE
+	if !fence {
+		f.armed = true
+	}
+	if (fence && !f.armed) || fence == f.fenced {
+		return
+	}
+	f.fenced = fence
+	f.pending = append(f.pending, fenceRequest{action: action, status: status})
+	select {
+	case f.getWakeup() <- struct{}{}:
+	default:
+	}
+}
+
+// run runs the queued requests till the stop channel is closed
+func (f *fencer) run(stopCh chan struct{}) {
+	defer f.hookRoutines.Wait()
+	f.lock.Lock()
+	wakeup := f.getWakeup()
+	f.lock.Unlock()
+	for {
+		f.lock.Lock()
+		pending := f.pending
+		f.pending = nil
+		hooks := append([]types.FenceHook{}, f.hooks...)
+		f.lock.Unlock()
+
+		for _, request := range pending {
+			for _, hook := range hooks {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+				f.runHook(hook, request, stopCh)
+			}
+		}
+		select {
+		case <-wakeup:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// runHook runs a hook and reports it if it misses its deadline. It returns
+// once the hook returns, or gossip is stopped in which case the hook is
+// cancelled.
+func (f *fencer) runHook(hook types.FenceHook, request fenceRequest, stopCh chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Deadline)
+	defer cancel()
+	done := make(chan error, 1)
+	start := time.Now()
+	f.hookRoutines.Add(1)
+	go func() {
+		defer f.hookRoutines.Done()
+		done <- hook.Fn(ctx, request.action, request.status)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			logrus.Warnf("gossip: Fence hook %v failed to %v: %v", hook.Name, request.action, err)
+		} else {
+			logrus.Infof("gossip: Fence hook %v completed %v in %v", hook.Name, request.action, time.Since(start))
+		}
+	case <-stopCh:
+		logrus.Infof("gossip: Cancelling fence hook %v as gossip is stopped", hook.Name)
+	case <-ctx.Done():
+		logrus.Errorf("gossip: Fence hook %v did not %v within its deadline of %v", hook.Name, request.action, hook.Deadline)
+		f.lock.Lock()
+		onTimeout := f.onTimeout
+		f.lock.Unlock()
+		if onTimeout != nil {
+			onTimeout(hook.Name, request.action)
+		}
+		// Do not run the next action while the hook is still running
+		select {
+		case err := <-done:
+			logrus.Warnf("gossip: Fence hook %v returned after %v: %v", hook.Name, time.Since(start), err)
+		case <-stopCh:
+		}
+	}
+}
+
+// startFencer starts running the fencing hooks in the background
+func (gd *GossipDelegate) startFencer(onTimeout func(hook string, action types.FenceAction)) {
+	gd.fencer.lock.Lock()
+	gd.fencer.onTimeout = onTimeout
+	gd.fencer.armed = false
+	gd.fencer.lock.Unlock()
+	stopCh := gd.getStateStopCh()
+	gd.routines.Add(1)
+	go func() {
+		defer gd.routines.Done()
+		gd.fencer.run(stopCh)
+	}()
+}
+
+// fenceOnStatusChange fences this node when it drops out of quorum and
+// unfences it when it regains quorum
+func (gd *GossipDelegate) fenceOnStatusChange(status types.NodeStatus) {
+	switch status {
+	case types.NODE_STATUS_NOT_IN_QUORUM, types.NODE_STATUS_DOWN:
+		gd.fencer.request(types.FENCE_ACTION_FENCE, status)
+	case types.NODE_STATUS_UP, types.NODE_STATUS_WITNESS:
+		gd.fencer.request(types.FENCE_ACTION_UNFENCE, status)
+	}
+}
+
+func (g *GossiperImpl) RegisterFenceHook(hook types.FenceHook) error {
+	return g.fencer.register(hook)
+}
+
+func (g *GossiperImpl) UnregisterFenceHook(name string) error {
+	return g.fencer.unregister(name)
+}
@@ -0,0 +1,182 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFenceHooks(t *testing.T) {
+	printTestInfo()
+
+	var lock sync.Mutex
+	calls := []string{}
+	done := make(chan struct{}, 10)
+	hook := func(name string) types.FenceHook {
+		return types.FenceHook{
+			Name: name,
+			Fn: func(ctx context.Context, action types.FenceAction, status types.NodeStatus) error {
+				lock.Lock()
+				calls = append(calls, fmt.Sprintf("%v:%v:%v", name, action, status))
+				lock.Unlock()
+				done <- struct{}{}
+				return nil
+			},
+		}
+	}
+	getCalls := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, calls...)
+	}
+	wait := func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Fence hooks were not invoked")
+			}
+		}
+	}
+
+	f := &fencer{}
+	require.NoError(t, f.register(hook("a")))
+	require.NoError(t, f.register(hook("b")))
+	require.Error(t, f.register(hook("a")), "Expected an error for a duplicate hook")
+	require.Error(t, f.register(types.FenceHook{Name: "c"}), "Expected an error for a hook without a function")
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		f.run(stopCh)
+		close(stopped)
+	}()
+
+	// A node which was never up is not fenced
+	f.request(types.FENCE_ACTION_FENCE, types.NODE_STATUS_NOT_IN_QUORUM)
+	// An unfence without a fence is not run
+	f.request(types.FENCE_ACTION_UNFENCE, types.NODE_STATUS_UP)
+	f.request(types.FENCE_ACTION_FENCE, types.NODE_STATUS_NOT_IN_QUORUM)
+	// A repeated fence is not run
+	f.request(types.FENCE_ACTION_FENCE, types.NODE_STATUS_DOWN)
+	wait(2)
+	require.Equal(t, []string{
+		"a:Fence:" + fmt.Sprint(types.NODE_STATUS_NOT_IN_QUORUM),
+		"b:Fence:" + fmt.Sprint(types.NODE_STATUS_NOT_IN_QUORUM),
+	}, getCalls())
+
+	require.NoError(t, f.unregister("a"))
+	require.Error(t, f.unregister("a"), "Expected an error for an unknown hook")
+	f.request(types.FENCE_ACTION_UNFENCE, types.NODE_STATUS_UP)
+	wait(1)
+	require.Equal(t, "b:Unfence:"+fmt.Sprint(types.NODE_STATUS_UP), getCalls()[2])
+	require.Len(t, getCalls(), 3)
+
+	close(stopCh)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Fencer did not stop")
+	}
+}
+
+func TestFenceHookDeadline(t *testing.T) {
+	printTestInfo()
+
+	timedOut := make(chan string, 1)
+	f := &fencer{
+		armed: true,
+		onTimeout: func(hook string, action types.FenceAction) {
+			timedOut <- hook + ":" + string(action)
+		},
+	}
+	// The hook ignores its context and only returns once released
+	release := make(chan struct{})
+	actions := make(chan types.FenceAction, 2)
+	require.NoError(t, f.register(types.FenceHook{
+		Name:     "slow",
+		Deadline: 100 * time.Millisecond,
+		Fn: func(ctx context.Context, action types.FenceAction, status types.NodeStatus) error {
+			actions <- action
+			if action == types.FENCE_ACTION_FENCE {
+				<-release
+			}
+			return ctx.Err()
+		},
+	}))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go f.run(stopCh)
+	f.request(types.FENCE_ACTION_FENCE, types.NODE_STATUS_NOT_IN_QUORUM)
+	require.Equal(t, types.FENCE_ACTION_FENCE, <-actions)
+
+	select {
+	case hook := <-timedOut:
+		require.Equal(t, "slow:Fence", hook)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deadline of the fence hook was not enforced")
+	}
+
+	// The unfence waits for the fence to return
+	f.request(types.FENCE_ACTION_UNFENCE, types.NODE_STATUS_UP)
+	select {
+	case action := <-actions:
+		t.Fatalf("Hook invoked to %v while the fence is still running", action)
+	case <-time.After(500 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case action := <-actions:
+		require.Equal(t, types.FENCE_ACTION_UNFENCE, action)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Unfence was not run after the fence returned")
+	}
+}
+
+func TestFenceHookStop(t *testing.T) {
+	printTestInfo()
+
+	f := &fencer{armed: true}
+	started := make(chan struct{})
+	returned := make(chan struct{})
+	require.NoError(t, f.register(types.FenceHook{
+		Name:     "blocking",
+		Deadline: time.Minute,
+		Fn: func(ctx context.Context, action types.FenceAction, status types.NodeStatus) error {
+			close(started)
+			<-ctx.Done()
+			// Returns a while after it is cancelled
+			time.Sleep(200 * time.Millisecond)
+			close(returned)
+			return ctx.Err()
+		},
+	}))
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		f.run(stopCh)
+		close(stopped)
+	}()
+	f.request(types.FENCE_ACTION_FENCE, types.NODE_STATUS_NOT_IN_QUORUM)
+	<-started
+
+	// Stopping cancels the hook and waits for it to return
+	close(stopCh)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Fencer did not stop")
+	}
+	select {
+	case <-returned:
+	default:
+		t.Fatalf("Fencer stopped before the hook returned")
+	}
+}
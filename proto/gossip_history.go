@@ -0,0 +1,266 @@
+package proto
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxStateHistoryLine is the longest line read from a history file
+const maxStateHistoryLine = 1024 * 1024
+
+// stateHistory is a ring buffer of the last state transitions of this node
+// which are optionally appended to a JSON lines file
+type stateHistory struct {
+	lock        sync.Mutex
+	transitions []types.StateTransition
+	// next is the position of the next transition in the ring buffer
+	next int
+	// full is true once the ring buffer wraps around
+	full bool
+	path string
+	file *os.File
+	// lines is the number of transitions in the file
+	lines int
+	// torn is true if the last write to the file failed and the file may
+	// not end with a newline
+	torn bool
+}
+
+func newStateHistory(size int) *stateHistory {
+	if size <= 0 {
+		size = types.DEFAULT_STATE_HISTORY_SIZE
+	}
+	return &stateHistory{
+		transitions: make([]types.StateTransition, size),
+	}
+}
+
+func (h *stateHistory) add(transition types.StateTransition) {
+	h.transitions[h.next] = transition
+	h.next = (h.next + 1) % len(h.transitions)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// record adds the transition to the history and appends it to the file.
+// The file is compacted once it holds twice as many transitions as the
+// history.
+func (h *stateHistory) record(transition types.StateTransition) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.add(transition)
+	if h.file == nil {
+		return
+	}
+	data, err := json.Marshal(transition)
+	if err != nil {
+		logrus.Warnf("gossip: Unable to encode state transition: %v", err)
+		return
+	}
+	data = append(data, '\n')
+	if h.torn {
+		data = append([]byte{'\n'}, data...)
+	}
+	if _, err := h.file.Write(data); err != nil {
+		logrus.Warnf("gossip: Unable to write state transition to %v: %v", h.path, err)
+		h.torn = true
+		return
+	}
+	h.torn = false
+	h.lines++
+	if h.lines < 2*len(h.transitions) {
+		return
+	}
+	file, err := h.compact()
+	if err != nil {
+		logrus.Warnf("gossip: Unable to compact state history %v: %v", h.path, err)
+		return
+	}
+	h.file.Close()
+	h.file = file
+}
+
+// ordered returns the transitions from the oldest to the latest
+func (h *stateHistory) ordered() []types.StateTransition {
+	if !h.full {
+		return append([]types.StateTransition{}, h.transitions[:h.next]...)
+	}
+	transitions := append([]types.StateTransition{}, h.transitions[h.next:]...)
+	return append(transitions, h.transitions[:h.next]...)
+}
+
+// get returns the transitions from the oldest to the latest
+func (h *stateHistory) get() []types.StateTransition {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.ordered()
+}
+
+// compact replaces the file with one holding only the transitions in the
+// history and returns it opened for appending
+func (h *stateHistory) compact() (*os.File, error) {
+	tmpPath := h.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	transitions := h.ordered()
+	w := bufio.NewWriter(tmp)
+	for _, transition := range transitions {
+		data, err := json.Marshal(transition)
+		if err != nil {
+			tmp.Close()
+			return nil, err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	h.lines = len(transitions)
+	h.torn = false
+	return file, nil
+}
+
+// load adds the transitions in the file to the history
+func (h *stateHistory) load() error {
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStateHistoryLine)
+	for scanner.Scan() {
+		var transition types.StateTransition
+		if err := json.Unmarshal(scanner.Bytes(), &transition); err != nil {
+			// The last line is torn if the process died while writing it
+			logrus.Warnf("gossip: Skipping invalid state transition in %v: %v", h.path, err)
+			continue
+		}
+		h.add(transition)
+	}
+	return scanner.Err()
+}
+
+// open opens the file to which the transitions are appended. The
+// transitions in the file are loaded if the history is empty, and the file
+// is compacted to the transitions in the history.
+func (h *stateHistory) open(path string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if path == "" {
+		return nil
+	}
+	h.path = path
+	if h.next == 0 && !h.full {
+		if err := h.load(); err != nil {
+			return fmt.Errorf("gossip: Unable to read state history %v: %v", path, err)
+		}
+	}
+	file, err := h.compact()
+	if err != nil {
+		return fmt.Errorf("gossip: Unable to open state history %v: %v", path, err)
+	}
+	h.file = file
+	return nil
+}
+
+func (h *stateHistory) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.file != nil {
+		h.file.Close()
+		h.file = nil
+	}
+}
+
+// initStateHistory creates the history of state transitions on the first
+// start and opens its file
+func (gd *GossipDelegate) initStateHistory(config types.StateHistoryConfig) error {
+	gd.currentStateLock.Lock()
+	if gd.stateHistory == nil {
+		gd.stateHistory = newStateHistory(config.Size)
+	}
+	history := gd.stateHistory
+	gd.currentStateLock.Unlock()
+	return history.open(config.Path)
+}
+
+func (gd *GossipDelegate) getStateHistory() *stateHistory {
+	gd.currentStateLock.Lock()
+	defer gd.currentStateLock.Unlock()
+	return gd.stateHistory
+}
+
+// closeStateHistory closes the file of the history. The transitions are
+// kept in memory across restarts.
+func (gd *GossipDelegate) closeStateHistory() {
+	if history := gd.getStateHistory(); history != nil {
+		history.close()
+	}
+}
+
+// recordStateTransition records a change in the status of this node
+func (gd *GossipDelegate) recordStateTransition(
+	event types.StateEvent,
+	prevStatus types.NodeStatus,
+	nextStatus types.NodeStatus,
+) {
+	history := gd.getStateHistory()
+	if history == nil {
+		return
+	}
+	localNodeInfoMap := gd.GetLocalState()
+	transition := types.StateTransition{
+		Ts:         time.Now(),
+		Event:      event,
+		PrevStatus: prevStatus,
+		NextStatus: nextStatus,
+		Quorum:     gd.explainQuorum(localNodeInfoMap),
+	}
+	for id, nodeInfo := range localNodeInfoMap {
+		if id == gd.NodeId() {
+			continue
+		}
+		transition.NumPeers++
+		if nodeInfo.Status == types.NODE_STATUS_UP || nodeInfo.Status == types.NODE_STATUS_WITNESS {
+			transition.NumPeersUp++
+		}
+	}
+	history.record(transition)
+}
+
+func (g *GossiperImpl) GetStateHistory() []types.StateTransition {
+	history := g.getStateHistory()
+	if history == nil {
+		return []types.StateTransition{}
+	}
+	return history.get()
+}
@@ -0,0 +1,91 @@
+package proto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateHistory(t *testing.T) {
+	printTestInfo()
+
+	dir, err := ioutil.TempDir("", "history")
+	require.NoError(t, err, "Failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "transitions.json")
+
+	h := newStateHistory(3)
+	require.Empty(t, h.get())
+	require.NoError(t, h.open(path), "Failed to open history")
+	statuses := []types.NodeStatus{
+		types.NODE_STATUS_NOT_IN_QUORUM,
+		types.NODE_STATUS_UP,
+		types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM,
+		types.NODE_STATUS_NOT_IN_QUORUM,
+		types.NODE_STATUS_UP,
+	}
+	for i := 1; i < len(statuses); i++ {
+		h.record(types.StateTransition{
+			Event:      types.NODE_LEAVE,
+			PrevStatus: statuses[i-1],
+			NextStatus: statuses[i],
+			Quorum: types.QuorumExplanation{
+				MissingMembers: map[types.NodeId]types.NodeStatus{"1": types.NODE_STATUS_DOWN},
+			},
+		})
+	}
+	// Only the last transitions are kept
+	transitions := h.get()
+	require.Len(t, transitions, 3)
+	for i, transition := range transitions {
+		require.Equal(t, statuses[i+1], transition.PrevStatus)
+		require.Equal(t, statuses[i+2], transition.NextStatus)
+	}
+	h.close()
+
+	// The transitions are loaded from the file after a restart
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"Ts\":")
+	require.NoError(t, err)
+	f.Close()
+	h = newStateHistory(10)
+	require.NoError(t, h.open(path), "Failed to open history")
+	defer h.close()
+	transitions = h.get()
+	require.Len(t, transitions, 4)
+	require.Equal(t, types.NODE_STATUS_UP, transitions[3].NextStatus)
+	require.Equal(t, types.NODE_LEAVE, transitions[3].Event)
+	require.Equal(t, types.NODE_STATUS_DOWN, transitions[3].Quorum.MissingMembers["1"])
+	require.Equal(t, "NODE_LEAVE", transitions[3].Event.String())
+
+	// The torn line is dropped from the file
+	require.Equal(t, 4, countLines(t, path))
+
+	// The file is compacted to the last transitions
+	for i := 0; i < 15; i++ {
+		h.record(types.StateTransition{Event: types.UPDATE_CLUSTER_SIZE})
+	}
+	require.Equal(t, 19, countLines(t, path))
+	h.record(types.StateTransition{Event: types.TIMEOUT})
+	require.Equal(t, 10, countLines(t, path))
+	h.close()
+	h = newStateHistory(10)
+	require.NoError(t, h.open(path), "Failed to open history")
+	defer h.close()
+	transitions = h.get()
+	require.Len(t, transitions, 10)
+	require.Equal(t, types.TIMEOUT, transitions[9].Event)
+}
+
+func countLines(t *testing.T, path string) int {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Failed to read history")
+	require.True(t, strings.HasSuffix(string(data), "\n"), "Expected file to end with a newline")
+	return strings.Count(string(data), "\n")
+}
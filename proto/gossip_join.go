@@ -0,0 +1,206 @@
+package proto
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// joinRetryConfigWithDefaults applies the defaults to the unset fields of
+// the join retry configuration
+func joinRetryConfigWithDefaults(config types.JoinRetryConfig) types.JoinRetryConfig {
+	if config.InitialBackoff > 0 {
+		config.Enabled = true
+	}
+	if !config.Enabled {
+		return config
+	}
+	if config.InitialBackoff == 0 {
+		config.InitialBackoff = types.DEFAULT_JOIN_RETRY_INITIAL_BACKOFF
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = types.DEFAULT_JOIN_RETRY_MAX_BACKOFF
+	}
+	if config.MaxBackoff < config.InitialBackoff {
+		config.MaxBackoff = config.InitialBackoff
+	}
+	if config.Multiplier < 1 {
+		config.Multiplier = types.DEFAULT_JOIN_RETRY_MULTIPLIER
+	}
+	return config
+}
+
+func (g *GossiperImpl) joinRetryEnabled() bool {
+	return g.joinRetryConfig.Enabled
+}
+
+// rejoinInterval returns the interval at which the known nodes which are
+// not alive are re-joined. If the periodic re-join is disabled, it is the
+// interval at which the discoverer is polled for new nodes.
+func (g *GossiperImpl) rejoinInterval() time.Duration {
+	if g.joinRetryConfig.RejoinInterval > 0 || g.discoverer == nil {
+		return g.joinRetryConfig.RejoinInterval
+	}
+	return g.discoveryInterval
+}
+
+// startRejoiner starts a go routine which retries the join to the seed nodes
+// till it succeeds and then periodically re-joins the known nodes which are
+// not alive, so that a partitioned cluster heals once the network does. The
+// nodes provided by the discoverer are joined as they show up.
+func (g *GossiperImpl) startRejoiner(joined bool) {
+	if !g.joinRetryEnabled() && g.rejoinInterval() == 0 {
+		return
+	}
+	g.rejoinStopCh = make(chan struct{})
+	g.routines.Add(1)
+	go g.rejoin(joined, g.rejoinStopCh)
+}
+
+func (g *GossiperImpl) stopRejoiner() {
+	if g.rejoinStopCh != nil {
+		close(g.rejoinStopCh)
+		g.rejoinStopCh = nil
+	}
+}
+
+func (g *GossiperImpl) rejoin(joined bool, stopCh chan struct{}) {
+	defer g.routines.Done()
+	backoff := g.joinRetryConfig.InitialBackoff
+	for {
+		wait := g.rejoinInterval()
+		if !joined && g.joinRetryEnabled() {
+			wait = backoff
+		}
+		if wait == 0 {
+			// Join succeeded and periodic re-join is disabled
+			return
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(wait):
+		}
+
+		addrs := g.rejoinAddrs(joined)
+		if len(addrs) == 0 {
+			continue
+		}
+		joinedNodes, err := g.getMemberlist().Join(addrs)
+		if err != nil {
+			if !joined {
+				backoff = nextJoinBackoff(backoff, g.joinRetryConfig)
+				log.Infof("gossip: Unable to join other nodes: %v. Retrying in %v", err, backoff)
+			} else {
+				log.Infof("gossip: Unable to re-join nodes %v: %v", addrs, err)
+			}
+			continue
+		}
+		if !joined {
+			log.Infof("gossip: Successfully joined with %v node(s)", joinedNodes)
+		} else {
+			log.Infof("gossip: Successfully re-joined with %v node(s)", joinedNodes)
+		}
+		joined = true
+		backoff = g.joinRetryConfig.InitialBackoff
+	}
+}
+
+// rejoinAddrs returns the addresses of the known nodes which are not alive,
+// if the periodic re-join is enabled, and of the discovered nodes which are
+// not alive. The seed addresses are included till we have joined the cluster.
+func (g *GossiperImpl) rejoinAddrs(joined bool) []string {
+	addrs := []string{}
+	seen := make(map[string]bool)
+	addAddr := func(addr string) {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	if !joined {
+		for _, addr := range g.seedIps {
+			addAddr(addr)
+		}
+	}
+	if discovered := g.discoverSeeds(); len(discovered) > 0 {
+		aliveAddrs := make(map[string]bool)
+		for _, member := range g.getMemberlist().Members() {
+			aliveAddrs[net.JoinHostPort(member.Addr.String(), strconv.Itoa(int(member.Port)))] = true
+		}
+		for _, addr := range discovered {
+			if !aliveAddrs[addr] {
+				addAddr(addr)
+			}
+		}
+	}
+	if joined && g.joinRetryConfig.RejoinInterval == 0 {
+		return addrs
+	}
+	for id, nodeInfo := range g.GetLocalState() {
+		if id == g.selfNodeId {
+			continue
+		}
+		if needsRejoin(nodeInfo.Status) {
+			addAddr(g.peerJoinAddr(nodeInfo))
+		}
+	}
+	return addrs
+}
+
+// needsRejoin returns false for nodes which are either alive or do not want
+// to be a part of the cluster
+func needsRejoin(status types.NodeStatus) bool {
+	switch status {
+	case types.NODE_STATUS_UP,
+		types.NODE_STATUS_WITNESS,
+		types.NODE_STATUS_MAINTENANCE,
+		types.NODE_STATUS_DAMPED,
+		types.NODE_STATUS_LEFT:
+		return false
+	}
+	return true
+}
+
+// discoverSeeds returns the seed addresses provided by the discoverer
+func (g *GossiperImpl) discoverSeeds() []string {
+	if g.discoverer == nil {
+		return nil
+	}
+	addrs, err := g.discoverer.Discover()
+	if err != nil {
+		log.Warnf("gossip: Unable to discover seed nodes using %v: %v", g.discoverer, err)
+		return nil
+	}
+	return addrs
+}
+
+// appendAddrs appends the addresses which are not already present
+func appendAddrs(addrs []string, newAddrs []string) []string {
+	for _, newAddr := range newAddrs {
+		exists := false
+		for _, addr := range addrs {
+			if addr == newAddr {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			addrs = append(addrs, newAddr)
+		}
+	}
+	return addrs
+}
+
+// nextJoinBackoff returns the backoff to use after a failed join. The
+// config is expected to have the defaults applied.
+func nextJoinBackoff(backoff time.Duration, config types.JoinRetryConfig) time.Duration {
+	next := time.Duration(float64(backoff) * config.Multiplier)
+	if next > config.MaxBackoff {
+		next = config.MaxBackoff
+	}
+	return next
+}
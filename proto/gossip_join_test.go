@@ -0,0 +1,186 @@
+package proto
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newGossiperImplWithJoinRetry(
+	ip string,
+	selfNodeId types.NodeId,
+	knownIps []string,
+	joinRetry types.JoinRetryConfig,
+	discoverer types.Discoverer,
+) (*GossiperImpl, error) {
+	knownNodesMap := make(map[string]string)
+	for _, knownIp := range knownIps {
+		knownNodesMap[knownIp] = ""
+	}
+	return newGossiperImpl(ip, selfNodeId, "", knownNodesMap, types.DEFAULT_GOSSIP_VERSION,
+		DEFAULT_CLUSTER_ID, types.QUORUM_PROVIDER_DEFAULT, nil,
+		func(config *types.GossipStartConfiguration) {
+			config.JoinRetry = joinRetry
+			config.Discoverer = discoverer
+		})
+}
+
+type testDiscoverer struct {
+	lock  sync.Mutex
+	addrs []string
+}
+
+func (d *testDiscoverer) Discover() ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.addrs, nil
+}
+
+func (d *testDiscoverer) setAddrs(addrs []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.addrs = addrs
+}
+
+func (d *testDiscoverer) String() string {
+	return "test discoverer"
+}
+
+func TestNextJoinBackoff(t *testing.T) {
+	printTestInfo()
+
+	config := types.JoinRetryConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+	require.Equal(t, 2*time.Second, nextJoinBackoff(time.Second, config))
+	require.Equal(t, 4*time.Second, nextJoinBackoff(2*time.Second, config))
+	require.Equal(t, 5*time.Second, nextJoinBackoff(4*time.Second, config))
+}
+
+func TestJoinRetryConfigDefaults(t *testing.T) {
+	printTestInfo()
+
+	// Join retries are disabled by default
+	require.Equal(t, types.JoinRetryConfig{}, joinRetryConfigWithDefaults(types.JoinRetryConfig{}))
+
+	config := joinRetryConfigWithDefaults(types.JoinRetryConfig{Enabled: true})
+	require.Equal(t, types.JoinRetryConfig{
+		Enabled:        true,
+		InitialBackoff: types.DEFAULT_JOIN_RETRY_INITIAL_BACKOFF,
+		MaxBackoff:     types.DEFAULT_JOIN_RETRY_MAX_BACKOFF,
+		Multiplier:     types.DEFAULT_JOIN_RETRY_MULTIPLIER,
+	}, config)
+	require.Equal(t, types.DEFAULT_JOIN_RETRY_MAX_BACKOFF,
+		nextJoinBackoff(types.DEFAULT_JOIN_RETRY_MAX_BACKOFF, config), "Expected backoff to be bounded")
+
+	// A partial config is completed with the defaults
+	config = joinRetryConfigWithDefaults(types.JoinRetryConfig{
+		InitialBackoff: 2 * time.Minute,
+		Multiplier:     0.5,
+	})
+	require.True(t, config.Enabled, "Expected join retries to be enabled")
+	require.Equal(t, 2*time.Minute, config.MaxBackoff)
+	require.Equal(t, types.DEFAULT_JOIN_RETRY_MULTIPLIER, config.Multiplier)
+}
+
+func TestGossiperJoinRetry(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9950",
+		"127.0.0.2:9951",
+	}
+
+	// Without join retries Start fails if the seed node is not up
+	g, err := newGossiperImplWithJoinRetry(nodesIp[0], "0", []string{nodesIp[1]}, types.JoinRetryConfig{}, nil)
+	require.Error(t, err, "Expected Start to fail without join retries")
+	g.mlist.Shutdown()
+
+	joinRetry := types.JoinRetryConfig{
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		RejoinInterval: time.Second,
+	}
+	gZero, err := newGossiperImplWithJoinRetry(nodesIp[0], "0", []string{nodesIp[1]}, joinRetry, nil)
+	require.NoError(t, err, "Expected Start to succeed with join retries")
+	require.Len(t, gZero.GetNodes(), 1)
+
+	// Bring up the seed node. Node 0 should join it in the background.
+	gOne, err := newGossiperImplWithJoinRetry(nodesIp[1], "1", []string{}, joinRetry, nil)
+	require.NoError(t, err, "Failed to start gossiper")
+
+	time.Sleep(types.DEFAULT_GOSSIP_INTERVAL * 2)
+	require.Len(t, gZero.GetNodes(), 2)
+	require.Len(t, gOne.GetNodes(), 2)
+
+	require.NoError(t, gZero.Stop(0), "Failed to stop gossiper")
+	require.NoError(t, gOne.Stop(0), "Failed to stop gossiper")
+}
+
+func TestGossiperJoinWithDiscoverer(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9952",
+		"127.0.0.2:9953",
+		"127.0.0.3:9954",
+	}
+	joinRetry := types.JoinRetryConfig{
+		RejoinInterval: time.Second,
+	}
+
+	// Discoverer returns all the nodes including ourselves
+	discoverer := &testDiscoverer{addrs: nodesIp[:2]}
+	gZero, err := newGossiperImplWithJoinRetry(nodesIp[0], "0", []string{}, joinRetry, discoverer)
+	require.NoError(t, err, "Failed to start gossiper")
+	gOne, err := newGossiperImplWithJoinRetry(nodesIp[1], "1", []string{}, joinRetry, discoverer)
+	require.NoError(t, err, "Failed to start gossiper")
+
+	// A node added to the discoverer later on is picked up by the re-joiner
+	gTwo, err := newGossiperImplWithJoinRetry(nodesIp[2], "2", []string{}, joinRetry, nil)
+	require.NoError(t, err, "Failed to start gossiper")
+	discoverer.setAddrs(nodesIp)
+
+	time.Sleep(types.DEFAULT_GOSSIP_INTERVAL * 2)
+	for _, g := range []*GossiperImpl{gZero, gOne, gTwo} {
+		require.Len(t, g.GetNodes(), 3)
+	}
+	for _, g := range []*GossiperImpl{gZero, gOne, gTwo} {
+		require.NoError(t, g.Stop(0), "Failed to stop gossiper")
+	}
+}
+
+func TestGossiperDiscoveryWithoutRejoin(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9955",
+		"127.0.0.2:9956",
+	}
+	// The discoverer is polled even though the periodic re-join is disabled
+	discoverer := &testDiscoverer{addrs: nodesIp[:1]}
+	withDiscoverer := func(config *types.GossipStartConfiguration) {
+		config.Discoverer = discoverer
+		config.DiscoveryInterval = time.Second
+	}
+	gZero, err := newGossiperImpl(nodesIp[0], "0", "", map[string]string{}, types.DEFAULT_GOSSIP_VERSION,
+		DEFAULT_CLUSTER_ID, types.QUORUM_PROVIDER_DEFAULT, nil, withDiscoverer)
+	require.NoError(t, err, "Failed to start gossiper")
+	gOne, err := NewGossiperImpl(nodesIp[1], "1", []string{}, types.DEFAULT_GOSSIP_VERSION)
+	require.NoError(t, err, "Failed to start gossiper")
+	require.Len(t, gZero.GetNodes(), 1)
+
+	discoverer.setAddrs(nodesIp)
+	time.Sleep(types.DEFAULT_GOSSIP_INTERVAL * 2)
+	require.Len(t, gZero.GetNodes(), 2)
+	require.Len(t, gOne.GetNodes(), 2)
+
+	require.NoError(t, gZero.Stop(0), "Failed to stop gossiper")
+	require.NoError(t, gOne.Stop(0), "Failed to stop gossiper")
+}
@@ -0,0 +1,176 @@
+package proto
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+// requireGoroutines waits for the number of go routines to drop to at most
+// n. It fails the test with the stacks of all the go routines otherwise.
+func requireGoroutines(t *testing.T, n int) {
+	num := runtime.NumGoroutine()
+	for i := 0; i < 50 && num > n; i++ {
+		time.Sleep(100 * time.Millisecond)
+		num = runtime.NumGoroutine()
+	}
+	if num > n {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		t.Fatalf("%v go routines left behind, expected at most %v:\n%s", num, n, buf)
+	}
+}
+
+func TestGossiperRestart(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9969",
+		"127.0.0.2:9970",
+	}
+	peers := map[types.NodeId]types.NodeUpdate{
+		"0": {Addr: nodesIp[0], QuorumMember: true},
+		"1": {Addr: nodesIp[1], QuorumMember: true},
+	}
+	g0, err := NewGossiperImpl(nodesIp[0], "0", []string{}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+	g0.UpdateCluster(peers)
+	// The go routines of node 0 and the test
+	numGoroutines := runtime.NumGoroutine()
+	g1, err := NewGossiperImpl(nodesIp[1], "1", []string{nodesIp[0]}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+	g1.UpdateCluster(peers)
+	defer g0.Stop(0)
+
+	waitForPeerStatus := func(g *GossiperImpl, id types.NodeId, status types.NodeStatus) {
+		for i := 0; i < 50; i++ {
+			if nodeInfo, err := g.GetLocalNodeInfo(id); err == nil && nodeInfo.Status == status {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		nodeInfo, _ := g.GetLocalNodeInfo(id)
+		require.Equal(t, status, nodeInfo.Status, "Unexpected status of node %v", id)
+	}
+	waitForPeerStatus(g0, "1", types.NODE_STATUS_UP)
+
+	config := types.GossipStartConfiguration{
+		Nodes: map[types.NodeId]types.GossipNodeConfiguration{
+			"0": {KnownUrl: nodesIp[0]},
+		},
+		QuorumProviderType: types.QUORUM_PROVIDER_DEFAULT,
+	}
+	require.Error(t, g1.Start(config), "Expected error starting a running gossiper")
+
+	// Restart the gossiper a few times. No go routines should be left behind.
+	require.NoError(t, g1.Stop(0), "Failed to stop gossiper")
+	requireGoroutines(t, numGoroutines)
+	require.Error(t, g1.Stop(0), "Expected error stopping a stopped gossiper")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, g1.Start(config), "Failed to restart gossiper")
+		waitForPeerStatus(g1, "0", types.NODE_STATUS_UP)
+		waitForPeerStatus(g0, "1", types.NODE_STATUS_UP)
+		require.Equal(t, types.NODE_STATUS_UP, g1.GetSelfStatus())
+		explanation := g1.ExplainQuorum()
+		require.True(t, explanation.InQuorum, "Expected node in quorum: %v", explanation.Reason)
+		require.Equal(t, []types.NodeId{"0", "1"}, explanation.UpMembers)
+		history := g1.GetStateHistory()
+		require.NotEmpty(t, history, "Expected state transitions")
+		require.Equal(t, types.NODE_STATUS_UP, history[len(history)-1].NextStatus)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		require.NoError(t, g1.StopWithContext(ctx, 0, ""), "Failed to stop gossiper")
+		cancel()
+		requireGoroutines(t, numGoroutines)
+	}
+
+	// Start honours the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, g1.StartWithContext(ctx, config), "Expected error with a cancelled context")
+	requireGoroutines(t, numGoroutines)
+}
+
+func TestGossiperStartUnknownQuorumProvider(t *testing.T) {
+	printTestInfo()
+
+	g := new(GossiperImpl)
+	gi := types.GossipIntervals{
+		GossipInterval:   types.DEFAULT_GOSSIP_INTERVAL,
+		PushPullInterval: types.DEFAULT_PUSH_PULL_INTERVAL,
+		ProbeInterval:    types.DEFAULT_PROBE_INTERVAL,
+		ProbeTimeout:     types.DEFAULT_PROBE_TIMEOUT,
+		QuorumTimeout:    TestQuorumTimeout,
+		SuspicionMult:    types.DEFAULT_SUSPICION_MULTIPLIER,
+	}
+	g.Init("127.0.0.1:9971", "0", 1, gi, types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	err := g.Start(types.GossipStartConfiguration{
+		QuorumProviderType: types.QuorumProvider(200),
+	})
+	require.Error(t, err, "Expected error for an unknown quorum provider")
+	require.False(t, g.ExplainQuorum().InQuorum, "Expected node not in quorum")
+	require.Error(t, g.Stop(0), "Expected error stopping a gossiper which did not start")
+}
+
+func TestGossiperStopInMaintenance(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9980",
+		"127.0.0.2:9981",
+		"127.0.0.3:9982",
+	}
+	peers := getNodeUpdateMap(nodesIp)
+	gossipers := []*GossiperImpl{}
+	for i, ip := range nodesIp {
+		knownIps := []string{}
+		if i > 0 {
+			knownIps = append(knownIps, nodesIp[0])
+		}
+		g, err := NewGossiperImpl(ip, types.NodeId(strconv.Itoa(i)), knownIps, types.GOSSIP_VERSION_2)
+		require.NoError(t, err, "Failed to start gossiper")
+		g.UpdateCluster(peers)
+		gossipers = append(gossipers, g)
+	}
+	defer gossipers[0].Stop(0)
+	defer gossipers[1].Stop(0)
+
+	nodeUp := func() bool {
+		for _, g := range gossipers[:2] {
+			nodeInfo, err := g.GetLocalNodeInfo("2")
+			if err != nil || nodeInfo.Status != types.NODE_STATUS_UP {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < 50 && !nodeUp(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.True(t, nodeUp(), "Node 2 is not up")
+
+	// Node 2 stops without leaving right after entering maintenance,
+	// before a push/pull could carry its maintenance mode
+	require.NoError(t, gossipers[2].EnterMaintenance(), "Failed to enter maintenance")
+	require.NoError(t, gossipers[2].Stop(0), "Failed to stop gossiper")
+
+	// The other nodes do not count node 2 in quorum even before they
+	// notice that it stopped
+	inMaintenance := func(g *GossiperImpl) bool {
+		nodeInfo, err := g.GetLocalNodeInfo("2")
+		return err == nil && nodeInfo.Maintenance &&
+			(nodeInfo.Status == types.NODE_STATUS_MAINTENANCE || nodeInfo.Status == types.NODE_STATUS_DOWN)
+	}
+	for _, g := range gossipers[:2] {
+		for i := 0; i < 50 && !inMaintenance(g); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		require.True(t, inMaintenance(g), "Expected node 2 to be in maintenance")
+		require.Equal(t, types.NODE_STATUS_UP, g.GetSelfStatus(), "Expected node to stay up")
+	}
+}
@@ -0,0 +1,139 @@
+package proto
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+)
+
+func (g *GossiperImpl) Members(filter types.MemberFilter) []types.Member {
+	var mlistNodes []*memberlist.Node
+	g.joinLock.Lock()
+	gossiping := g.hasJoinedCluster && !g.shutDown
+	if gossiping {
+		mlistNodes = g.mlist.Members()
+	}
+	g.joinLock.Unlock()
+	return g.buildMembers(mlistNodes, gossiping, filter)
+}
+
+// buildMembers combines the memberlist nodes with the nodes in our store and
+// returns the ones matching the filter
+func (gd *GossipDelegate) buildMembers(
+	mlistNodes []*memberlist.Node,
+	gossiping bool,
+	filter types.MemberFilter,
+) []types.Member {
+	nodeInfoMap, lastHeardTs := gd.getMembersState()
+
+	members := make(map[types.NodeId]*types.Member)
+	for id, nodeInfo := range nodeInfoMap {
+		member := &types.Member{
+			Id:              id,
+			Status:          nodeInfo.Status,
+			MemberlistState: types.MEMBERLIST_STATE_UNKNOWN,
+			ClusterDomain:   nodeInfo.ClusterDomain,
+			QuorumMember:    nodeInfo.QuorumMember,
+			Witness:         nodeInfo.Witness,
+			GenNumber:       nodeInfo.GenNumber,
+			LastHeardTs:     lastHeardTs[id],
+			Labels:          nodeInfo.Labels,
+		}
+		if gossiping {
+			member.MemberlistState = types.MEMBERLIST_STATE_DEAD
+		}
+		if host, port, err := net.SplitHostPort(nodeInfo.Addr); err == nil {
+			member.Addr = host
+			if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+				member.Port = uint16(p)
+			}
+		} else {
+			member.Addr = nodeInfo.Addr
+		}
+		members[id] = member
+	}
+
+	for _, node := range mlistNodes {
+		id := types.NodeId(gd.parseMemberlistNodeName(node))
+		member, ok := members[id]
+		if !ok {
+			// Node which has not been added to our store yet
+			member = &types.Member{
+				Id:          id,
+				LastHeardTs: lastHeardTs[id],
+			}
+			if nodeMeta, err := gd.nodeMetaInfo(node); err == nil {
+				member.Labels = nodeMeta.Labels
+			}
+			members[id] = member
+		}
+		member.Addr = node.Addr.String()
+		member.Port = node.Port
+		member.MemberlistState = types.MEMBERLIST_STATE_ALIVE
+	}
+
+	if self, ok := members[types.NodeId(gd.nodeId)]; ok {
+		self.LastHeardTs = time.Now()
+	}
+
+	result := []types.Member{}
+	for _, member := range members {
+		if memberMatches(member, filter) {
+			result = append(result, *member)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func memberMatches(member *types.Member, filter types.MemberFilter) bool {
+	if len(filter.Ids) > 0 {
+		found := false
+		for _, id := range filter.Ids {
+			if id == member.Id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(filter.Statuses) > 0 {
+		found := false
+		for _, status := range filter.Statuses {
+			if status == member.Status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(filter.MemberlistStates) > 0 {
+		found := false
+		for _, state := range filter.MemberlistStates {
+			if state == member.MemberlistState {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.ClusterDomain != "" && filter.ClusterDomain != member.ClusterDomain {
+		return false
+	}
+	if filter.QuorumMember != nil && *filter.QuorumMember != member.QuorumMember {
+		return false
+	}
+	return labelsMatch(member.Labels, filter.Labels)
+}
@@ -0,0 +1,96 @@
+package proto
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func getMemberIds(members []types.Member) []types.NodeId {
+	ids := []types.NodeId{}
+	for _, member := range members {
+		ids = append(ids, member.Id)
+	}
+	return ids
+}
+
+func TestGossipDelegateMembers(t *testing.T) {
+	printTestInfo()
+
+	peers := map[types.NodeId]types.NodeUpdate{
+		"0": {Addr: "127.0.0.1:9000", QuorumMember: true, ClusterDomain: "zone1"},
+		"1": {Addr: "127.0.0.2:9000", QuorumMember: true, ClusterDomain: "zone1"},
+		"2": {Addr: "127.0.0.3:9000", QuorumMember: false, ClusterDomain: "zone2"},
+	}
+	gd := newTestGossipDelegate("0", peers, types.QUORUM_PROVIDER_DEFAULT)
+
+	// Not gossiping yet
+	members := gd.buildMembers(nil, false, types.MemberFilter{})
+	require.Equal(t, []types.NodeId{"0", "1", "2"}, getMemberIds(members))
+	require.Equal(t, types.MEMBERLIST_STATE_UNKNOWN, members[1].MemberlistState)
+	require.Equal(t, "127.0.0.2", members[1].Addr)
+	require.Equal(t, uint16(9000), members[1].Port)
+	require.True(t, members[1].LastHeardTs.IsZero(), "Expected node not heard yet")
+
+	gs := NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateSelfLabels(map[string]string{"rack": "r1"})
+	node1 := newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo())
+	node1.Addr = net.ParseIP("127.0.0.2")
+	node1.Port = 9002
+	gd.NotifyJoin(node1)
+	require.NoError(t, gd.NotifyAlive(node1), "Unexpected error on NotifyAlive")
+
+	// Node which is not in our store yet
+	gs = NewGossipStore("3", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateSelfLabels(map[string]string{"rack": "r2"})
+	node3 := newTestMemberlistNodeWithMeta(t, "3", gs.MetaInfo())
+	node3.Addr = net.ParseIP("127.0.0.4")
+	node3.Port = 9000
+
+	self := &memberlist.Node{
+		Name: "0" + types.GOSSIP_VERSION_2,
+		Addr: net.ParseIP("127.0.0.1"),
+		Port: 9000,
+	}
+	mlistNodes := []*memberlist.Node{self, node1, node3}
+
+	members = gd.buildMembers(mlistNodes, true, types.MemberFilter{})
+	require.Equal(t, []types.NodeId{"0", "1", "2", "3"}, getMemberIds(members))
+	require.Equal(t, types.MEMBERLIST_STATE_ALIVE, members[1].MemberlistState)
+	require.Equal(t, uint16(9002), members[1].Port)
+	require.Equal(t, types.NODE_STATUS_UP, members[1].Status)
+	require.Equal(t, "zone1", members[1].ClusterDomain)
+	require.Equal(t, "r1", members[1].Labels["rack"])
+	require.False(t, members[1].LastHeardTs.IsZero(), "Expected node to be heard")
+	require.Equal(t, types.MEMBERLIST_STATE_DEAD, members[2].MemberlistState)
+	require.Equal(t, "127.0.0.4", members[3].Addr)
+	require.Equal(t, "r2", members[3].Labels["rack"])
+
+	// Filters
+	quorumMember := false
+	filters := map[string]struct {
+		filter   types.MemberFilter
+		expected []types.NodeId
+	}{
+		"ids":      {types.MemberFilter{Ids: []types.NodeId{"1", "3"}}, []types.NodeId{"1", "3"}},
+		"statuses": {types.MemberFilter{Statuses: []types.NodeStatus{types.NODE_STATUS_INVALID}}, []types.NodeId{"3"}},
+		"states": {
+			types.MemberFilter{MemberlistStates: []types.MemberlistState{types.MEMBERLIST_STATE_DEAD}},
+			[]types.NodeId{"2"},
+		},
+		"domain":       {types.MemberFilter{ClusterDomain: "zone1"}, []types.NodeId{"0", "1"}},
+		"quorumMember": {types.MemberFilter{QuorumMember: &quorumMember}, []types.NodeId{"2", "3"}},
+		"labels":       {types.MemberFilter{Labels: map[string]string{"rack": "r2"}}, []types.NodeId{"3"}},
+		"combined": {
+			types.MemberFilter{ClusterDomain: "zone1", Labels: map[string]string{"rack": "r1"}},
+			[]types.NodeId{"1"},
+		},
+	}
+	for name, f := range filters {
+		require.Equal(t, f.expected, getMemberIds(gd.buildMembers(mlistNodes, true, f.filter)),
+			"Unexpected members for filter %v", name)
+	}
+}
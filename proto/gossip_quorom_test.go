@@ -2,6 +2,7 @@ package proto
 
 import (
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,7 +44,7 @@ func TestQuorumAllNodesUpOneByOne(t *testing.T) {
 	node0 := types.NodeId("0")
 	g0, _ := startNode(t, nodes[0], node0, []string{},
 		map[types.NodeId]types.NodeUpdate{
-			node0: types.NodeUpdate{nodes[0], true, ""}})
+			node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""}})
 
 	time.Sleep(g0.GossipInterval())
 	status := g0.GetSelfStatus()
@@ -55,8 +56,8 @@ func TestQuorumAllNodesUpOneByOne(t *testing.T) {
 	// Start Node1 with cluster size 2
 	node1 := types.NodeId("1")
 	peers := map[types.NodeId]types.NodeUpdate{
-		node0: types.NodeUpdate{nodes[0], true, ""},
-		node1: types.NodeUpdate{nodes[1], true, ""}}
+		node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""},
+		node1: types.NodeUpdate{Addr: nodes[1], QuorumMember: true, ClusterDomain: ""}}
 	g1, _ := startNode(t, nodes[1], node1, []string{nodes[0]}, peers)
 	g0.UpdateCluster(peers)
 
@@ -88,7 +89,7 @@ func TestQuorumNodeLoosesQuorumAndGainsBack(t *testing.T) {
 	// Start Node 0
 	g0, _ := startNode(t, nodes[0], node0, []string{},
 		map[types.NodeId]types.NodeUpdate{
-			node0: types.NodeUpdate{nodes[0], true, ""}})
+			node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""}})
 
 	time.Sleep(g0.GossipInterval())
 	selfStatus := g0.GetSelfStatus()
@@ -100,8 +101,8 @@ func TestQuorumNodeLoosesQuorumAndGainsBack(t *testing.T) {
 	// Simulate new node was added by updating the cluster size, but the new node is not talking to node0
 	// Node 0 should loose quorom 1/2
 	g0.UpdateCluster(map[types.NodeId]types.NodeUpdate{
-		node0: types.NodeUpdate{nodes[0], true, ""},
-		node1: types.NodeUpdate{nodes[1], true, ""}})
+		node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""},
+		node1: types.NodeUpdate{Addr: nodes[1], QuorumMember: true, ClusterDomain: ""}})
 	time.Sleep(g0.GossipInterval() * time.Duration(len(nodes)+1))
 	selfStatus = g0.GetSelfStatus()
 	if selfStatus != types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
@@ -121,8 +122,8 @@ func TestQuorumNodeLoosesQuorumAndGainsBack(t *testing.T) {
 	// Lets start the actual Node 1
 	g1, _ := startNode(t, nodes[1], node1, []string{nodes[0]},
 		map[types.NodeId]types.NodeUpdate{
-			node0: types.NodeUpdate{nodes[0], true, ""},
-			node1: types.NodeUpdate{nodes[1], true, ""}})
+			node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""},
+			node1: types.NodeUpdate{Addr: nodes[1], QuorumMember: true, ClusterDomain: ""}})
 
 	// Sleep so that nodes gossip
 	time.Sleep(g1.GossipInterval() * time.Duration(len(nodes)+1))
@@ -151,7 +152,7 @@ func TestQuorumTwoNodesLooseConnectivity(t *testing.T) {
 	node1 := types.NodeId("1")
 	g0, _ := startNode(t, nodes[0], node0, []string{},
 		map[types.NodeId]types.NodeUpdate{
-			node0: types.NodeUpdate{nodes[0], true, ""}})
+			node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""}})
 
 	time.Sleep(g0.GossipInterval())
 	if g0.GetSelfStatus() != types.NODE_STATUS_UP {
@@ -161,8 +162,8 @@ func TestQuorumTwoNodesLooseConnectivity(t *testing.T) {
 	// Simulate new node was added by updating the cluster size, but the new node is not talking to node0
 	// Node 0 should loose quorom 1/2
 	g0.UpdateCluster(map[types.NodeId]types.NodeUpdate{
-		node0: types.NodeUpdate{nodes[0], true, ""},
-		node1: types.NodeUpdate{nodes[1], true, ""}})
+		node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""},
+		node1: types.NodeUpdate{Addr: nodes[1], QuorumMember: true, ClusterDomain: ""}})
 	time.Sleep(g0.GossipInterval() * time.Duration(len(nodes)+1))
 	if g0.GetSelfStatus() != types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
 		t.Error("Expected Node 0 to have status: ", types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM)
@@ -172,8 +173,8 @@ func TestQuorumTwoNodesLooseConnectivity(t *testing.T) {
 	// to simulate NO connectivity between node 0 and node 1
 	g1, _ := startNode(t, nodes[1], node1, []string{},
 		map[types.NodeId]types.NodeUpdate{
-			node0: types.NodeUpdate{nodes[0], true, ""},
-			node1: types.NodeUpdate{nodes[1], true, ""}})
+			node0: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""},
+			node1: types.NodeUpdate{Addr: nodes[1], QuorumMember: true, ClusterDomain: ""}})
 
 	// For node 0 the status will change from UP_WAITING_QUORUM to WAITING_QUORUM after
 	// the quorum timeout
@@ -261,7 +262,7 @@ func TestQuorumNetworkPartition(t *testing.T) {
 		g, _ = startNode(t, nodes[i], nodeId,
 			[]string{nodes[0], nodes[1], nodes[2]},
 			map[types.NodeId]types.NodeUpdate{
-				nodeId: types.NodeUpdate{nodes[i], true, ""}})
+				nodeId: types.NodeUpdate{Addr: nodes[i], QuorumMember: true, ClusterDomain: ""}})
 		gossipers = append(gossipers, g)
 	}
 	// Parition 2
@@ -270,7 +271,7 @@ func TestQuorumNetworkPartition(t *testing.T) {
 		var g *GossiperImpl
 		g, _ = startNode(t, nodes[i], nodeId, []string{nodes[3], nodes[4]},
 			map[types.NodeId]types.NodeUpdate{
-				nodeId: types.NodeUpdate{nodes[i], true, ""}})
+				nodeId: types.NodeUpdate{Addr: nodes[i], QuorumMember: true, ClusterDomain: ""}})
 		gossipers = append(gossipers, g)
 	}
 	// Let the nodes gossip
@@ -330,7 +331,7 @@ func TestQuorumEventHandling(t *testing.T) {
 		var g *GossiperImpl
 		g, _ = startNode(t, nodes[i], nodeId, []string{nodes[0]},
 			map[types.NodeId]types.NodeUpdate{
-				nodeId: types.NodeUpdate{nodes[0], true, ""}})
+				nodeId: types.NodeUpdate{Addr: nodes[0], QuorumMember: true, ClusterDomain: ""}})
 		gossipers = append(gossipers, g)
 	}
 
@@ -357,14 +358,25 @@ func TestQuorumEventHandling(t *testing.T) {
 		}
 	}
 
-	// Bring node 3,node 2, node 1 down
-	gossipers[3].Stop(types.DEFAULT_GOSSIP_INTERVAL * time.Duration(len(nodes)))
-	gossipers[2].Stop(types.DEFAULT_GOSSIP_INTERVAL * time.Duration(len(nodes)))
-	gossipers[1].Stop(types.DEFAULT_GOSSIP_INTERVAL * time.Duration(len(nodes)))
-
-	time.Sleep(types.DEFAULT_GOSSIP_INTERVAL * time.Duration(len(nodes)+1))
-	//time.Sleep(types.DEFAULT_GOSSIP_INTERVAL)
+	// Bring node 3,node 2, node 1 down. A graceful leave takes a few
+	// gossip rounds, so the nodes leave together to stay well within the
+	// quorum timeout of node 0.
+	var wg sync.WaitGroup
+	for _, i := range []int{3, 2, 1} {
+		wg.Add(1)
+		go func(g *GossiperImpl) {
+			defer wg.Done()
+			g.Stop(types.DEFAULT_GOSSIP_INTERVAL * time.Duration(len(nodes)))
+		}(gossipers[i])
+	}
+	wg.Wait()
 
+	for i := 0; i < len(nodes)+1; i++ {
+		if gossipers[0].GetSelfStatus() == types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
+			break
+		}
+		time.Sleep(types.DEFAULT_GOSSIP_INTERVAL)
+	}
 	if gossipers[0].GetSelfStatus() != types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
 		t.Error("Expected Node 0 status to be ", types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM, " Got: ", gossipers[0].GetSelfStatus())
 	}
@@ -474,7 +486,7 @@ func TestQuorumAddNodes(t *testing.T) {
 	node0Ip := "127.0.0.1:9923"
 	node0 := types.NodeId("0")
 	peers := make(map[types.NodeId]types.NodeUpdate)
-	peers[node0] = types.NodeUpdate{node0Ip, true, ""}
+	peers[node0] = types.NodeUpdate{Addr: node0Ip, QuorumMember: true, ClusterDomain: ""}
 	g0, _ := startNode(t, node0Ip, node0, []string{}, peers)
 
 	// Lets sleep so that the nodes gossip and update their quorum
@@ -488,7 +500,7 @@ func TestQuorumAddNodes(t *testing.T) {
 	// Add a new node
 	node1 := types.NodeId("1")
 	node1Ip := "127.0.0.2:9924"
-	peers[node1] = types.NodeUpdate{node1Ip, true, ""}
+	peers[node1] = types.NodeUpdate{Addr: node1Ip, QuorumMember: true, ClusterDomain: ""}
 	g0.UpdateCluster(peers)
 
 	time.Sleep(types.DEFAULT_GOSSIP_INTERVAL)
@@ -553,7 +565,7 @@ func TestNonQuorumMembersAddRemove(t *testing.T) {
 	for i, node := range newNodes {
 		quorumMember := i != 0
 		nodeId := types.NodeId(strconv.Itoa(len(peers)))
-		peers[nodeId] = types.NodeUpdate{node, quorumMember, ""}
+		peers[nodeId] = types.NodeUpdate{Addr: node, QuorumMember: quorumMember, ClusterDomain: ""}
 		for _, g := range gossipers {
 			g.UpdateCluster(peers)
 		}
@@ -63,12 +63,12 @@ func setupTestNodes(t *testing.T) (map[string]string, map[types.NodeId]types.Nod
 	}
 
 	peers := map[types.NodeId]types.NodeUpdate{
-		types.NodeId("0"): types.NodeUpdate{"127.0.0.1:9300", true, zone1},
-		types.NodeId("1"): types.NodeUpdate{"127.0.0.1:9301", true, zone1},
-		types.NodeId("2"): types.NodeUpdate{"127.0.0.1:9302", true, zone2},
-		types.NodeId("3"): types.NodeUpdate{"127.0.0.1:9303", true, zone2},
-		types.NodeId("4"): types.NodeUpdate{"127.0.0.1:9304", true, zone3},
-		types.NodeId("5"): types.NodeUpdate{"127.0.0.1:9305", true, zone3},
+		types.NodeId("0"): types.NodeUpdate{Addr: "127.0.0.1:9300", QuorumMember: true, ClusterDomain: zone1},
+		types.NodeId("1"): types.NodeUpdate{Addr: "127.0.0.1:9301", QuorumMember: true, ClusterDomain: zone1},
+		types.NodeId("2"): types.NodeUpdate{Addr: "127.0.0.1:9302", QuorumMember: true, ClusterDomain: zone2},
+		types.NodeId("3"): types.NodeUpdate{Addr: "127.0.0.1:9303", QuorumMember: true, ClusterDomain: zone2},
+		types.NodeId("4"): types.NodeUpdate{Addr: "127.0.0.1:9304", QuorumMember: true, ClusterDomain: zone3},
+		types.NodeId("5"): types.NodeUpdate{Addr: "127.0.0.1:9305", QuorumMember: true, ClusterDomain: zone3},
 	}
 
 	gossipers := make([]*GossiperImpl, len(nodes))
@@ -124,7 +124,8 @@ func TestQuorumFdOneNodeDown(t *testing.T) {
 		}
 		peerDownNode, err := g.GetLocalNodeInfo(downNodeId)
 		require.NoError(t, err, "Unexpected error on GetLocalNodeInfo")
-		require.Equal(t, types.NODE_STATUS_DOWN, peerDownNode.Status, "Unexpected state found in %v for peer node %v", id, downNodeId)
+		// Node 0 was stopped with a leave timeout, so it left gracefully
+		require.Equal(t, types.NODE_STATUS_LEFT, peerDownNode.Status, "Unexpected state found in %v for peer node %v", id, downNodeId)
 	}
 
 	shutdownTestNodes(gossipers)
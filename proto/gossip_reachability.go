@@ -0,0 +1,125 @@
+package proto
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+const (
+	// reachabilityStaleMultiplier is the number of push pull intervals after
+	// which a node's reachability view is considered stale
+	reachabilityStaleMultiplier = 5
+)
+
+// GetReachabilityReport builds a cluster wide reachability matrix from the
+// reachability views gossiped by all the nodes and reports suspected
+// partitions
+func (g *GossiperImpl) GetReachabilityReport() types.ReachabilityReport {
+	g.updateSelfReachability()
+	staleTimeout := g.mlConf.PushPullInterval * reachabilityStaleMultiplier
+	return buildReachabilityReport(g.GetLocalState(), staleTimeout, time.Now())
+}
+
+// buildReachabilityReport builds a reachability report out of the views present
+// in the given nodeInfoMap. Views older than staleTimeout are ignored.
+func buildReachabilityReport(
+	nodeInfoMap types.NodeInfoMap,
+	staleTimeout time.Duration,
+	now time.Time,
+) types.ReachabilityReport {
+	report := types.ReachabilityReport{
+		Matrix:          make(types.ReachabilityMatrix),
+		Groups:          [][]types.NodeId{},
+		AsymmetricLinks: []types.NodeLink{},
+		Unreachable:     []types.NodeId{},
+	}
+
+	// Collect the views which are recent enough
+	for id, nodeInfo := range nodeInfoMap {
+		if nodeInfo.ReachableTs.IsZero() ||
+			now.Sub(nodeInfo.ReachableTs) > staleTimeout {
+			continue
+		}
+		view := make(map[types.NodeId]bool)
+		for _, peer := range nodeInfo.Reachable {
+			if _, ok := nodeInfoMap[peer]; ok && peer != id {
+				view[peer] = true
+			}
+		}
+		report.Matrix[id] = view
+	}
+
+	// neighbours is the set of nodes with which a node has a working link.
+	// If both the nodes have a view the link needs to be seen from both
+	// sides, otherwise we go with the only view that we have.
+	neighbours := make(map[types.NodeId]map[types.NodeId]bool)
+	for id := range nodeInfoMap {
+		neighbours[id] = make(map[types.NodeId]bool)
+	}
+	for from, view := range report.Matrix {
+		for to := range view {
+			toView, hasView := report.Matrix[to]
+			if hasView && !toView[from] {
+				report.AsymmetricLinks = append(
+					report.AsymmetricLinks,
+					types.NodeLink{From: from, To: to},
+				)
+				continue
+			}
+			neighbours[from][to] = true
+			neighbours[to][from] = true
+		}
+	}
+	sort.Slice(report.AsymmetricLinks, func(i, j int) bool {
+		if report.AsymmetricLinks[i].From != report.AsymmetricLinks[j].From {
+			return report.AsymmetricLinks[i].From < report.AsymmetricLinks[j].From
+		}
+		return report.AsymmetricLinks[i].To < report.AsymmetricLinks[j].To
+	})
+
+	// Nodes which see the same set of nodes (including themselves) are
+	// on the same side of a partition
+	groupsByView := make(map[string][]types.NodeId)
+	for id, peers := range neighbours {
+		if _, hasView := report.Matrix[id]; !hasView && len(peers) == 0 {
+			report.Unreachable = append(report.Unreachable, id)
+			continue
+		}
+		members := []types.NodeId{id}
+		for peer := range peers {
+			members = append(members, peer)
+		}
+		sortNodeIds(members)
+		key := joinNodeIds(members)
+		groupsByView[key] = append(groupsByView[key], id)
+	}
+	for _, group := range groupsByView {
+		sortNodeIds(group)
+		report.Groups = append(report.Groups, group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i][0] < report.Groups[j][0]
+	})
+	sortNodeIds(report.Unreachable)
+
+	report.PartitionSuspected = len(report.Groups) > 1 ||
+		len(report.AsymmetricLinks) > 0
+	return report
+}
+
+func sortNodeIds(ids []types.NodeId) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+}
+
+func joinNodeIds(ids []types.NodeId) string {
+	strIds := make([]string, len(ids))
+	for i, id := range ids {
+		strIds[i] = string(id)
+	}
+	return strings.Join(strIds, ",")
+}
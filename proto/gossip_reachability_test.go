@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func getReachabilityNodeInfoMap(
+	views map[types.NodeId][]types.NodeId,
+	now time.Time,
+) types.NodeInfoMap {
+	nodeInfoMap := make(types.NodeInfoMap)
+	for id, reachable := range views {
+		nodeInfo := types.NodeInfo{
+			Id:     id,
+			Status: types.NODE_STATUS_UP,
+		}
+		if reachable != nil {
+			nodeInfo.Reachable = reachable
+			nodeInfo.ReachableTs = now
+		}
+		nodeInfoMap[id] = nodeInfo
+	}
+	return nodeInfoMap
+}
+
+func TestReachabilityAllNodesConnected(t *testing.T) {
+	printTestInfo()
+
+	now := time.Now()
+	nodeInfoMap := getReachabilityNodeInfoMap(
+		map[types.NodeId][]types.NodeId{
+			"0": {"1", "2"},
+			"1": {"0", "2"},
+			"2": {"0", "1"},
+		},
+		now,
+	)
+	report := buildReachabilityReport(nodeInfoMap, time.Minute, now)
+	require.False(t, report.PartitionSuspected, "Expected no partition")
+	require.Equal(t, [][]types.NodeId{{"0", "1", "2"}}, report.Groups)
+	require.Empty(t, report.AsymmetricLinks)
+	require.Empty(t, report.Unreachable)
+	require.Len(t, report.Matrix, 3)
+}
+
+func TestReachabilityPartialPartition(t *testing.T) {
+	printTestInfo()
+
+	// Nodes 0,1 and 3,4 cannot see each other, node 2 sees everyone
+	now := time.Now()
+	nodeInfoMap := getReachabilityNodeInfoMap(
+		map[types.NodeId][]types.NodeId{
+			"0": {"1", "2"},
+			"1": {"0", "2"},
+			"2": {"0", "1", "3", "4"},
+			"3": {"2", "4"},
+			"4": {"2", "3"},
+		},
+		now,
+	)
+	report := buildReachabilityReport(nodeInfoMap, time.Minute, now)
+	require.True(t, report.PartitionSuspected, "Expected a partition")
+	require.Equal(t, [][]types.NodeId{{"0", "1"}, {"2"}, {"3", "4"}}, report.Groups)
+	require.Empty(t, report.AsymmetricLinks)
+}
+
+func TestReachabilityAsymmetricLink(t *testing.T) {
+	printTestInfo()
+
+	now := time.Now()
+	nodeInfoMap := getReachabilityNodeInfoMap(
+		map[types.NodeId][]types.NodeId{
+			"0": {"1", "2"},
+			"1": {"2"},
+			"2": {"0", "1"},
+		},
+		now,
+	)
+	report := buildReachabilityReport(nodeInfoMap, time.Minute, now)
+	require.True(t, report.PartitionSuspected, "Expected a partition")
+	require.Equal(t, []types.NodeLink{{From: "0", To: "1"}}, report.AsymmetricLinks)
+}
+
+func TestReachabilityStaleAndMissingViews(t *testing.T) {
+	printTestInfo()
+
+	now := time.Now()
+	nodeInfoMap := getReachabilityNodeInfoMap(
+		map[types.NodeId][]types.NodeId{
+			"0": {"1"},
+			"1": {"0"},
+			// 2 has never published a view and nobody reaches it
+			"2": nil,
+			// 3 published a stale view and nobody reaches it
+			"3": {"0", "1", "2"},
+		},
+		now,
+	)
+	stale := nodeInfoMap["3"]
+	stale.ReachableTs = now.Add(-2 * time.Minute)
+	nodeInfoMap["3"] = stale
+
+	report := buildReachabilityReport(nodeInfoMap, time.Minute, now)
+	require.False(t, report.PartitionSuspected, "Expected no partition")
+	require.Equal(t, []types.NodeId{"2", "3"}, report.Unreachable)
+	require.Equal(t, [][]types.NodeId{{"0", "1"}}, report.Groups)
+	require.Len(t, report.Matrix, 2)
+}
@@ -0,0 +1,186 @@
+package proto
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// addrResolver resolves the DNS names in peer addresses and remembers the
+// last resolved address for every name
+type addrResolver struct {
+	sync.Mutex
+	// preferIPv6 selects IPv6 addresses for names which resolve to
+	// both IPv4 and IPv6 addresses
+	preferIPv6 bool
+	resolved   map[string]string
+	lookupIP   func(host string) ([]net.IP, error)
+}
+
+func newAddrResolver() *addrResolver {
+	return &addrResolver{
+		resolved: make(map[string]string),
+		lookupIP: net.LookupIP,
+	}
+}
+
+// resolve returns the ip:port form of a host:port address. The name is looked
+// up if it has not been resolved yet or if refresh is set. It also returns
+// true if the resolved address changed since the last lookup.
+func (r *addrResolver) resolve(addr string, refresh bool) (string, bool, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", false, err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, false, nil
+	}
+
+	r.Lock()
+	prevAddr, ok := r.resolved[addr]
+	r.Unlock()
+	if ok && !refresh {
+		return prevAddr, false, nil
+	}
+
+	ips, err := r.lookupIP(host)
+	if err != nil {
+		return prevAddr, false, fmt.Errorf("failed to resolve %v: %v", host, err)
+	}
+	ip := r.pickIP(ips, prevAddr)
+	if ip == nil {
+		return prevAddr, false, fmt.Errorf("failed to resolve %v: no addresses found", host)
+	}
+	resolvedAddr := net.JoinHostPort(ip.String(), port)
+
+	r.Lock()
+	r.resolved[addr] = resolvedAddr
+	r.Unlock()
+	return resolvedAddr, ok && prevAddr != resolvedAddr, nil
+}
+
+// pickIP returns the previously resolved ip if the name still resolves to it,
+// so that names with multiple addresses do not flip between them. Otherwise
+// the first ip of the preferred family is returned.
+func (r *addrResolver) pickIP(ips []net.IP, prevAddr string) net.IP {
+	if prevAddr != "" {
+		prevHost, _, _ := net.SplitHostPort(prevAddr)
+		for _, ip := range ips {
+			if ip.String() == prevHost {
+				return ip
+			}
+		}
+	}
+	var fallback net.IP
+	for _, ip := range ips {
+		if (ip.To4() == nil) == r.preferIPv6 {
+			return ip
+		}
+		if fallback == nil {
+			fallback = ip
+		}
+	}
+	return fallback
+}
+
+// isHostnameAddr returns true if the host in a host:port address is not
+// an ip address
+func isHostnameAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && net.ParseIP(host) == nil
+}
+
+// isIPv6Addr returns true if the host in a host:port address is an
+// IPv6 address
+func isIPv6Addr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// startResolver starts a go routine which periodically resolves the DNS names
+// in the peer addresses again
+func (g *GossiperImpl) startResolver() {
+	if g.resolveInterval <= 0 {
+		return
+	}
+	g.resolveStopCh = make(chan struct{})
+	g.routines.Add(1)
+	go g.resolvePeriodically(g.resolveInterval, g.resolveStopCh)
+}
+
+func (g *GossiperImpl) stopResolver() {
+	if g.resolveStopCh != nil {
+		close(g.resolveStopCh)
+		g.resolveStopCh = nil
+	}
+}
+
+func (g *GossiperImpl) resolvePeriodically(interval time.Duration, stopCh chan struct{}) {
+	defer g.routines.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		for id := range g.GetLocalState() {
+			if id != g.selfNodeId {
+				g.resolvePeer(id)
+			}
+		}
+	}
+}
+
+// resolvePeer resolves the DNS name in a peer's configured address again.
+// If the peer is not alive and its name now resolves to a new ip, we join it
+// at the new ip.
+func (g *GossiperImpl) resolvePeer(nodeId types.NodeId) {
+	nodeInfo, err := g.GetLocalNodeInfo(nodeId)
+	if err != nil || !isHostnameAddr(nodeInfo.ConfiguredAddr) {
+		return
+	}
+	resolvedAddr, changed, err := g.resolver.resolve(nodeInfo.ConfiguredAddr, true)
+	if err != nil {
+		log.Warnf("gossip: Unable to resolve address of node %v: %v", nodeId, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	log.Infof("gossip: Address %v of node %v now resolves to %v", nodeInfo.ConfiguredAddr, nodeId, resolvedAddr)
+	if !needsRejoin(nodeInfo.Status) {
+		return
+	}
+
+	g.joinLock.Lock()
+	gossiping := g.hasJoinedCluster && !g.shutDown
+	g.joinLock.Unlock()
+	if !gossiping {
+		return
+	}
+	if _, err := g.getMemberlist().Join([]string{resolvedAddr}); err != nil {
+		log.Infof("gossip: Unable to join node %v at %v: %v", nodeId, resolvedAddr, err)
+	}
+}
+
+// peerJoinAddr returns the address at which we join a peer. A DNS name in
+// the configured address is preferred over the advertised address, which
+// is stale once the peer moves to another ip.
+func (g *GossiperImpl) peerJoinAddr(nodeInfo types.NodeInfo) string {
+	if isHostnameAddr(nodeInfo.ConfiguredAddr) {
+		if resolvedAddr, _, err := g.resolver.resolve(nodeInfo.ConfiguredAddr, false); err == nil {
+			return resolvedAddr
+		}
+	}
+	return nodeInfo.Addr
+}
@@ -0,0 +1,173 @@
+package proto
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+type testLookup struct {
+	sync.Mutex
+	hosts map[string][]string
+}
+
+func (l *testLookup) set(host string, ips ...string) {
+	l.Lock()
+	defer l.Unlock()
+	l.hosts[host] = ips
+}
+
+func (l *testLookup) lookupIP(host string) ([]net.IP, error) {
+	l.Lock()
+	defer l.Unlock()
+	ips, ok := l.hosts[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host %v", host)
+	}
+	netIps := []net.IP{}
+	for _, ip := range ips {
+		netIps = append(netIps, net.ParseIP(ip))
+	}
+	return netIps, nil
+}
+
+func TestAddrResolver(t *testing.T) {
+	printTestInfo()
+
+	lookup := &testLookup{hosts: make(map[string][]string)}
+	lookup.set("node1.local", "10.0.0.1", "fd00::1")
+	r := newAddrResolver()
+	r.lookupIP = lookup.lookupIP
+
+	addr, changed, err := r.resolve("10.0.0.5:9002", false)
+	require.NoError(t, err, "Failed to resolve ip address")
+	require.Equal(t, "10.0.0.5:9002", addr)
+	require.False(t, changed, "Expected no change")
+
+	addr, changed, err = r.resolve("node1.local:9002", false)
+	require.NoError(t, err, "Failed to resolve")
+	require.Equal(t, "10.0.0.1:9002", addr)
+	require.False(t, changed, "Expected no change on first lookup")
+
+	// Dual stack peers use the preferred family
+	r6 := newAddrResolver()
+	r6.lookupIP = lookup.lookupIP
+	r6.preferIPv6 = true
+	addr, _, err = r6.resolve("node1.local:9002", false)
+	require.NoError(t, err, "Failed to resolve")
+	require.Equal(t, "[fd00::1]:9002", addr)
+
+	// Cached address is used unless refreshed
+	lookup.set("node1.local", "10.0.0.2")
+	addr, _, err = r.resolve("node1.local:9002", false)
+	require.NoError(t, err, "Failed to resolve")
+	require.Equal(t, "10.0.0.1:9002", addr)
+	addr, changed, err = r.resolve("node1.local:9002", true)
+	require.NoError(t, err, "Failed to resolve")
+	require.Equal(t, "10.0.0.2:9002", addr)
+	require.True(t, changed, "Expected address to change")
+
+	// Address sticks while the name still resolves to it
+	lookup.set("node1.local", "10.0.0.3", "10.0.0.2")
+	addr, changed, err = r.resolve("node1.local:9002", true)
+	require.NoError(t, err, "Failed to resolve")
+	require.Equal(t, "10.0.0.2:9002", addr)
+	require.False(t, changed, "Expected no change")
+
+	// Failed lookups return the last known address
+	lookup.set("node1.local")
+	addr, _, err = r.resolve("node1.local:9002", true)
+	require.Error(t, err, "Expected error")
+	require.Equal(t, "10.0.0.2:9002", addr)
+
+	_, _, err = r.resolve("unknown.local:9002", false)
+	require.Error(t, err, "Expected error for unknown host")
+	_, _, err = r.resolve("node1.local", false)
+	require.Error(t, err, "Expected error for address without port")
+}
+
+func TestGossiperPingHostname(t *testing.T) {
+	printTestInfo()
+
+	nodesIp := []string{
+		"127.0.0.1:9957",
+		"127.0.0.2:9958",
+	}
+	gZero, err := NewGossiperImpl(nodesIp[0], "0", []string{}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+	gOne, err := NewGossiperImpl(nodesIp[1], "1", []string{nodesIp[0]}, types.GOSSIP_VERSION_2)
+	require.NoError(t, err, "Failed to start gossiper")
+
+	lookup := &testLookup{hosts: make(map[string][]string)}
+	gZero.resolver.lookupIP = lookup.lookupIP
+
+	lookup.set("node1.local", "127.0.0.2")
+	_, err = gZero.Ping("1", "node1.local:9958")
+	require.NoError(t, err, "Failed to ping node by name")
+
+	// Node moves to a new ip. The name is resolved again on a failed ping.
+	lookup.set("node1.local", "127.0.0.3")
+	gZero.resolver.resolve("node1.local:9958", true)
+	lookup.set("node1.local", "127.0.0.2")
+	_, err = gZero.Ping("1", "node1.local:9958")
+	require.NoError(t, err, "Failed to ping node after re-resolution")
+
+	_, err = gZero.Ping("1", "unknown.local:9958")
+	require.Error(t, err, "Expected ping to fail for unknown host")
+
+	require.NoError(t, gZero.Stop(0), "Failed to stop gossiper")
+	require.NoError(t, gOne.Stop(0), "Failed to stop gossiper")
+}
+
+func TestGossiperResolveConfiguredAddr(t *testing.T) {
+	printTestInfo()
+
+	g := new(GossiperImpl)
+	g.Init("127.0.0.1:9959", "0", 1, types.GossipIntervals{}, types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	lookup := &testLookup{hosts: make(map[string][]string)}
+	g.resolver.lookupIP = lookup.lookupIP
+	g.joinRetryConfig.RejoinInterval = time.Minute
+	g.UpdateCluster(map[types.NodeId]types.NodeUpdate{
+		"0": {Addr: "127.0.0.1:9959", QuorumMember: true},
+		"1": {Addr: "node1.local:9000", QuorumMember: true},
+	})
+
+	// The peer advertises its ip. The configured name is kept.
+	gs := NewGossipStore("1", types.GOSSIP_VERSION_2, DEFAULT_CLUSTER_ID, "")
+	gs.updateSelfAdvertiseAddr("10.0.0.1:9000")
+	g.NotifyJoin(newTestMemberlistNodeWithMeta(t, "1", gs.MetaInfo()))
+	remoteState := g.GetLocalState()
+	nodeInfo := remoteState["1"]
+	nodeInfo.ConfiguredAddr = ""
+	nodeInfo.LastUpdateTs = time.Now()
+	remoteState["1"] = nodeInfo
+	g.update(remoteState)
+	nodeInfo, err := g.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, "10.0.0.1:9000", nodeInfo.Addr)
+	require.Equal(t, "node1.local:9000", nodeInfo.ConfiguredAddr)
+
+	// The peer is rescheduled to a new ip. Its name is resolved again and
+	// it is re-joined at the new ip.
+	lookup.set("node1.local", "10.0.0.1")
+	require.Equal(t, "10.0.0.1:9000", g.peerJoinAddr(nodeInfo))
+	lookup.set("node1.local", "10.0.0.2")
+	g.resolvePeer("1")
+	require.Equal(t, "10.0.0.2:9000", g.peerJoinAddr(nodeInfo))
+	require.Equal(t, []string{"10.0.0.2:9000"}, g.rejoinAddrs(true))
+
+	// A later UpdateCluster does not override the advertised address
+	g.UpdateCluster(map[types.NodeId]types.NodeUpdate{
+		"0": {Addr: "127.0.0.1:9959", QuorumMember: true},
+		"1": {Addr: "node1.example:9000", QuorumMember: true},
+	})
+	nodeInfo, err = g.GetLocalNodeInfo("1")
+	require.NoError(t, err, "Failed to get node info")
+	require.Equal(t, "10.0.0.1:9000", nodeInfo.Addr)
+	require.Equal(t, "node1.example:9000", nodeInfo.ConfiguredAddr)
+}
@@ -25,6 +25,14 @@ type GossipStoreImpl struct {
 	selfCorrect   bool
 	GossipVersion string
 	ClusterId     string
+	// prevClusterId is the cluster id migrated from. It is accepted
+	// from the peers till prevClusterIdDeadline.
+	prevClusterId         string
+	prevClusterIdDeadline time.Time
+	// minGossipVersion and maxGossipVersion are the range of gossip
+	// versions this node is compatible with
+	minGossipVersion string
+	maxGossipVersion string
 	// This cluster size is updated from an external source
 	// such as a kv database. This is an extra measure to find the
 	// number of nodes in the cluster other than just relying on
@@ -38,6 +46,12 @@ type GossipStoreImpl struct {
 	failureDomainsMapLock sync.Mutex
 	// Ts at which we lost quorum
 	lostQuorumTs time.Time
+	// lastHeardTs is the time at which we last heard from or about a node
+	lastHeardTs map[types.NodeId]time.Time
+	// advertiseAddr is the ip:port address advertised to the other nodes
+	advertiseAddr string
+	// startTs is the time at which this store was initialized
+	startTs time.Time
 }
 
 func NewGossipStore(id types.NodeId, version, clusterId, selfClusterDomain string) *GossipStoreImpl {
@@ -70,6 +84,8 @@ func (s *GossipStoreImpl) InitStore(
 	selfClusterDomain string,
 ) {
 	s.nodeMap = make(types.NodeInfoMap)
+	s.lastHeardTs = make(map[types.NodeId]time.Time)
+	s.startTs = time.Now()
 	s.id = id
 	s.selfCorrect = true
 	s.GossipVersion = version
@@ -94,11 +110,38 @@ func (s *GossipStoreImpl) updateSelfTs() {
 	s.nodeMap[s.id] = nodeInfo
 }
 
+// updateSelfReachability refreshes the list of peers this node can reach
+// based on the status memberlist has reported for them
+func (s *GossipStoreImpl) updateSelfReachability() {
+	s.Lock()
+	defer s.Unlock()
+
+	reachable := []types.NodeId{}
+	for id, nodeInfo := range s.nodeMap {
+		if id == s.id {
+			continue
+		}
+		if nodeInfo.Status == types.NODE_STATUS_UP ||
+			nodeInfo.Status == types.NODE_STATUS_WITNESS ||
+			nodeInfo.Status == types.NODE_STATUS_MAINTENANCE {
+			reachable = append(reachable, id)
+		}
+	}
+	nodeInfo, _ := s.nodeMap[s.id]
+	nodeInfo.Reachable = reachable
+	nodeInfo.ReachableTs = time.Now()
+	s.nodeMap[s.id] = nodeInfo
+}
+
 func (s *GossipStoreImpl) UpdateSelf(key types.StoreKey, val interface{}) {
 	s.Lock()
 	defer s.Unlock()
 
 	nodeInfo, ok := s.nodeMap[s.id]
+	if ok && nodeInfo.Witness {
+		logrus.Warnf("gossip: Witness node does not store key %v", key)
+		return
+	}
 	if ok {
 		if nodeInfo.Value == nil {
 			nodeInfo.Value = make(types.StoreMap)
@@ -126,6 +169,229 @@ func (s *GossipStoreImpl) updateSelfClusterDomain(selfClusterDomain string) bool
 	return false
 }
 
+// updateSelfMaintenance puts this node in or takes it out of maintenance.
+// It returns true if the maintenance mode changed.
+func (s *GossipStoreImpl) updateSelfMaintenance(maintenance bool) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, _ := s.nodeMap[s.id]
+	if nodeInfo.Maintenance == maintenance {
+		return false
+	}
+	nodeInfo.Maintenance = maintenance
+	nodeInfo.LastUpdateTs = time.Now()
+	s.nodeMap[s.id] = nodeInfo
+	return true
+}
+
+// updateSelfWitnessVote sets the quorum member to which this witness gives
+// its vote. It returns true if the vote changed.
+func (s *GossipStoreImpl) updateSelfWitnessVote(vote types.NodeId) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, _ := s.nodeMap[s.id]
+	if nodeInfo.WitnessVote == vote {
+		return false
+	}
+	nodeInfo.WitnessVote = vote
+	nodeInfo.LastUpdateTs = time.Now()
+	s.nodeMap[s.id] = nodeInfo
+	return true
+}
+
+// updateNodeLeaving records whether a node is gracefully leaving the
+// cluster along with the reason for leaving
+func (s *GossipStoreImpl) updateNodeLeaving(
+	nodeId types.NodeId,
+	leaving bool,
+	leaveReason string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, ok := s.nodeMap[nodeId]
+	if !ok {
+		return fmt.Errorf("Node with id (%v) not found", nodeId)
+	}
+	if nodeInfo.Leaving == leaving && nodeInfo.LeaveReason == leaveReason {
+		return nil
+	}
+	nodeInfo.Leaving = leaving
+	nodeInfo.LeaveReason = leaveReason
+	nodeInfo.LastUpdateTs = time.Now()
+	s.nodeMap[nodeId] = nodeInfo
+	return nil
+}
+
+// updateNodeMaintenance records whether a node is in maintenance as
+// published in its memberlist meta data. It returns true if the maintenance
+// mode of the node changed.
+func (s *GossipStoreImpl) updateNodeMaintenance(
+	nodeId types.NodeId,
+	maintenance bool,
+) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, ok := s.nodeMap[nodeId]
+	if !ok {
+		return false, fmt.Errorf("Node with id (%v) not found", nodeId)
+	}
+	if nodeInfo.Maintenance == maintenance {
+		return false, nil
+	}
+	nodeInfo.Maintenance = maintenance
+	alive := nodeInfo.Status == types.NODE_STATUS_UP ||
+		nodeInfo.Status == types.NODE_STATUS_WITNESS
+	if maintenance && alive {
+		nodeInfo.Status = types.NODE_STATUS_MAINTENANCE
+	} else if !maintenance && nodeInfo.Status == types.NODE_STATUS_MAINTENANCE {
+		nodeInfo.Status = aliveStatus(nodeInfo)
+	}
+	nodeInfo.LastUpdateTs = time.Now()
+	s.nodeMap[nodeId] = nodeInfo
+	return true, nil
+}
+
+// updateSelfLabels replaces the labels of this node
+func (s *GossipStoreImpl) updateSelfLabels(labels map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, _ := s.nodeMap[s.id]
+	nodeInfo.Labels = copyLabels(labels)
+	nodeInfo.LabelsTs = time.Now()
+	nodeInfo.LastUpdateTs = nodeInfo.LabelsTs
+	s.nodeMap[s.id] = nodeInfo
+}
+
+// updateNodeLabels sets the labels of a peer node as published in its
+// memberlist meta data, unless we already have more recent labels
+func (s *GossipStoreImpl) updateNodeLabels(
+	nodeId types.NodeId,
+	labels map[string]string,
+	labelsTs time.Time,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, ok := s.nodeMap[nodeId]
+	if !ok {
+		return fmt.Errorf("Node with id (%v) not found", nodeId)
+	}
+	if !labelsTs.After(nodeInfo.LabelsTs) {
+		return nil
+	}
+	nodeInfo.Labels = copyLabels(labels)
+	nodeInfo.LabelsTs = labelsTs
+	s.nodeMap[nodeId] = nodeInfo
+	return nil
+}
+
+// GetNodesByLabel returns the ids of the nodes which have all the
+// key/value labels in the selector
+func (s *GossipStoreImpl) GetNodesByLabel(selector map[string]string) []types.NodeId {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeIds := []types.NodeId{}
+	for id, nodeInfo := range s.nodeMap {
+		if labelsMatch(nodeInfo.Labels, selector) {
+			nodeIds = append(nodeIds, id)
+		}
+	}
+	sortNodeIds(nodeIds)
+	return nodeIds
+}
+
+func labelsMatch(labels map[string]string, selector map[string]string) bool {
+	for key, value := range selector {
+		if labelValue, ok := labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	labelsCopy := make(map[string]string, len(labels))
+	for key, value := range labels {
+		labelsCopy[key] = value
+	}
+	return labelsCopy
+}
+
+// updateSelfAdvertiseAddr sets the address advertised to the other nodes.
+// It returns true if the address changed.
+func (s *GossipStoreImpl) updateSelfAdvertiseAddr(addr string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.advertiseAddr == addr {
+		return false
+	}
+	s.advertiseAddr = addr
+	return true
+}
+
+func (s *GossipStoreImpl) getAdvertiseAddr() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.advertiseAddr
+}
+
+// updateNodeAddr sets the address of a peer node as advertised in its
+// memberlist meta data
+func (s *GossipStoreImpl) updateNodeAddr(nodeId types.NodeId, addr string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	nodeInfo, ok := s.nodeMap[nodeId]
+	if !ok {
+		return fmt.Errorf("Node with id (%v) not found", nodeId)
+	}
+	if nodeInfo.Addr != addr {
+		logrus.Infof("gossip: Node %v advertised a new address %v. Old address: %v",
+			nodeId, addr, nodeInfo.Addr)
+		nodeInfo.Addr = addr
+		s.nodeMap[nodeId] = nodeInfo
+	}
+	return nil
+}
+
+// updateNodeHeardTs records that we heard from the given node
+func (s *GossipStoreImpl) updateNodeHeardTs(nodeId types.NodeId) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastHeardTs[nodeId] = time.Now()
+}
+
+// resetNodeStatuses forgets the node statuses seen by an earlier run of
+// gossip. We start again with a NOT_IN_QUORUM status and all the peers down.
+func (s *GossipStoreImpl) resetNodeStatuses() {
+	s.Lock()
+	defer s.Unlock()
+
+	for id, nodeInfo := range s.nodeMap {
+		if id == s.id {
+			nodeInfo.Status = types.NODE_STATUS_NOT_IN_QUORUM
+			nodeInfo.Leaving = false
+			nodeInfo.LeaveReason = ""
+		} else {
+			nodeInfo.Status = types.NODE_STATUS_DOWN
+		}
+		nodeInfo.LastUpdateTs = time.Now()
+		s.nodeMap[id] = nodeInfo
+	}
+	s.lastHeardTs = make(map[types.NodeId]time.Time)
+}
+
 func (s *GossipStoreImpl) UpdateSelfStatus(status types.NodeStatus) {
 	s.UpdateNodeStatus(s.id, status)
 }
@@ -158,6 +424,10 @@ func (s *GossipStoreImpl) GetStoreKeyValue(key types.StoreKey) types.NodeValueMa
 
 	nodeValueMap := make(types.NodeValueMap)
 	for id, nodeInfo := range s.nodeMap {
+		if nodeInfo.Witness {
+			// Witnesses carry no store data
+			continue
+		}
 		if statusValid(nodeInfo.Status) && nodeInfo.Value != nil {
 			ok := len(nodeInfo.Value) == 0
 			val, exists := nodeInfo.Value[key]
@@ -180,7 +450,7 @@ func (s *GossipStoreImpl) GetStoreKeys() []types.StoreKey {
 
 	keyMap := make(map[types.StoreKey]bool)
 	for _, nodeInfo := range s.nodeMap {
-		if nodeInfo.Value != nil {
+		if nodeInfo.Value != nil && !nodeInfo.Witness {
 			for key := range nodeInfo.Value {
 				keyMap[key] = true
 			}
@@ -199,10 +469,81 @@ func (s *GossipStoreImpl) GetGossipVersion() string {
 	return s.GossipVersion
 }
 
+// getGossipVersionRange returns the range of gossip versions this node is
+// compatible with
+func (s *GossipStoreImpl) getGossipVersionRange() (string, string) {
+	s.Lock()
+	defer s.Unlock()
+	return gossipVersionRange(s.GossipVersion, s.minGossipVersion, s.maxGossipVersion)
+}
+
+func (s *GossipStoreImpl) setGossipVersionRange(minVersion, maxVersion string) {
+	s.Lock()
+	defer s.Unlock()
+	s.minGossipVersion = minVersion
+	s.maxGossipVersion = maxVersion
+}
+
 func (s *GossipStoreImpl) GetClusterId() string {
+	s.Lock()
+	defer s.Unlock()
 	return s.ClusterId
 }
 
+// startClusterIdMigration switches to a new cluster id. The current cluster
+// id is accepted from the peers till the deadline.
+func (s *GossipStoreImpl) startClusterIdMigration(clusterId string, deadline time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	if clusterId == "" || clusterId == s.ClusterId {
+		return fmt.Errorf("gossip: Invalid cluster id %q to migrate to", clusterId)
+	}
+	if s.prevClusterId != "" && time.Now().Before(s.prevClusterIdDeadline) {
+		return fmt.Errorf("gossip: Migration from cluster id %v is in progress", s.prevClusterId)
+	}
+	s.prevClusterId = s.ClusterId
+	s.prevClusterIdDeadline = deadline
+	s.ClusterId = clusterId
+	return nil
+}
+
+// getClusterIdMigration returns the cluster id migrated from, the end of its
+// migration window and whether the window is still open
+func (s *GossipStoreImpl) getClusterIdMigration() (string, time.Time, bool) {
+	s.Lock()
+	defer s.Unlock()
+	return s.prevClusterId, s.prevClusterIdDeadline, s.clusterIdMigrationActive()
+}
+
+func (s *GossipStoreImpl) clusterIdMigrationActive() bool {
+	return s.prevClusterId != "" && time.Now().Before(s.prevClusterIdDeadline)
+}
+
+// acceptsClusterId returns true if we gossip with a peer which published the
+// given meta data. During a migration both the old and the new cluster ids
+// are accepted.
+func (s *GossipStoreImpl) acceptsClusterId(nodeMeta types.NodeMetaInfo) bool {
+	s.Lock()
+	defer s.Unlock()
+	if nodeMeta.ClusterId == s.ClusterId {
+		return true
+	}
+	if s.clusterIdMigrationActive() && nodeMeta.ClusterId == s.prevClusterId {
+		return true
+	}
+	// The peer is migrating away from our cluster id
+	return nodeMeta.PrevClusterId != "" && nodeMeta.PrevClusterId == s.ClusterId
+}
+
+// aliveStatus returns the status of a node which is alive and not in
+// maintenance. Witnesses never become UP.
+func aliveStatus(nodeInfo types.NodeInfo) types.NodeStatus {
+	if nodeInfo.Witness {
+		return types.NODE_STATUS_WITNESS
+	}
+	return types.NODE_STATUS_UP
+}
+
 func statusValid(s types.NodeStatus) bool {
 	return (s != types.NODE_STATUS_INVALID &&
 		s != types.NODE_STATUS_NEVER_GOSSIPED)
@@ -260,6 +601,7 @@ func (s *GossipStoreImpl) removeNodeUnlocked(id types.NodeId) error {
 	}
 	logrus.Infof("gossip: Removing node from gossip map: %v", id)
 	delete(s.nodeMap, id)
+	delete(s.lastHeardTs, id)
 	return nil
 }
 
@@ -269,10 +611,22 @@ func (s *GossipStoreImpl) MetaInfo() types.NodeMetaInfo {
 
 	selfNodeInfo, _ := s.nodeMap[s.id]
 	nodeMetaInfo := types.NodeMetaInfo{
-		Id:            selfNodeInfo.Id,
-		LastUpdateTs:  selfNodeInfo.LastUpdateTs,
-		GossipVersion: s.GossipVersion,
-		ClusterId:     s.ClusterId,
+		Id:               selfNodeInfo.Id,
+		LastUpdateTs:     selfNodeInfo.LastUpdateTs,
+		GossipVersion:    s.GossipVersion,
+		MinGossipVersion: s.minGossipVersion,
+		MaxGossipVersion: s.maxGossipVersion,
+		ClusterId:        s.ClusterId,
+		Leaving:          selfNodeInfo.Leaving,
+		LeaveReason:      selfNodeInfo.LeaveReason,
+		Labels:           selfNodeInfo.Labels,
+		LabelsTs:         selfNodeInfo.LabelsTs,
+		Addr:             s.advertiseAddr,
+		StartTs:          s.startTs,
+		Maintenance:      selfNodeInfo.Maintenance,
+	}
+	if s.clusterIdMigrationActive() {
+		nodeMetaInfo.PrevClusterId = s.prevClusterId
 	}
 	return nodeMetaInfo
 }
@@ -283,6 +637,19 @@ func (s *GossipStoreImpl) GetLocalState() types.NodeInfoMap {
 	return s.getLocalState()
 }
 
+// getMembersState returns a copy of our nodeMap along with the time at
+// which we last heard from each node
+func (s *GossipStoreImpl) getMembersState() (types.NodeInfoMap, map[types.NodeId]time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	lastHeardTs := make(map[types.NodeId]time.Time, len(s.lastHeardTs))
+	for id, ts := range s.lastHeardTs {
+		lastHeardTs[id] = ts
+	}
+	return s.getLocalState(), lastHeardTs
+}
+
 func (s *GossipStoreImpl) GetLocalStateInBytes() ([]byte, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -301,9 +668,16 @@ func (s *GossipStoreImpl) GetLocalNodeInfo(id types.NodeId) (types.NodeInfo, err
 }
 
 func (s *GossipStoreImpl) Update(diff types.NodeInfoMap) {
+	s.update(diff)
+}
+
+// update merges the newly available data in our nodeMap. It returns true
+// if any node entered or exited maintenance or a witness moved its vote.
+func (s *GossipStoreImpl) update(diff types.NodeInfoMap) bool {
 	s.Lock()
 	defer s.Unlock()
 
+	quorumUpdated := false
 	for id, newNodeInfo := range diff {
 		if id == s.id {
 			continue
@@ -319,9 +693,36 @@ func (s *GossipStoreImpl) Update(diff types.NodeInfoMap) {
 			// memberlist. We should not update the Status field in our
 			// nodeInfo based on what other node's value is.
 			newNodeInfo.Status = selfValue.Status
+			// The address of a node is configured locally or advertised
+			// in its memberlist meta data
+			newNodeInfo.Addr = selfValue.Addr
+			newNodeInfo.ConfiguredAddr = selfValue.ConfiguredAddr
+			// Keep the labels received in the node's memberlist meta
+			// data if they are more recent than what the other nodes have
+			if selfValue.LabelsTs.After(newNodeInfo.LabelsTs) {
+				newNodeInfo.Labels = selfValue.Labels
+				newNodeInfo.LabelsTs = selfValue.LabelsTs
+			}
+			if newNodeInfo.Maintenance != selfValue.Maintenance ||
+				newNodeInfo.WitnessVote != selfValue.WitnessVote {
+				quorumUpdated = true
+			}
+			// A node which memberlist sees alive reflects its maintenance
+			// mode in its status
+			alive := selfValue.Status == types.NODE_STATUS_UP ||
+				selfValue.Status == types.NODE_STATUS_WITNESS
+			if newNodeInfo.Maintenance && alive {
+				newNodeInfo.Status = types.NODE_STATUS_MAINTENANCE
+			} else if !newNodeInfo.Maintenance && selfValue.Status == types.NODE_STATUS_MAINTENANCE {
+				newNodeInfo.Status = aliveStatus(newNodeInfo)
+			} else if alive {
+				newNodeInfo.Status = aliveStatus(newNodeInfo)
+			}
 			s.nodeMap[id] = newNodeInfo
+			s.lastHeardTs[id] = time.Now()
 		}
 	}
+	return quorumUpdated
 }
 
 func (s *GossipStoreImpl) updateCluster(
@@ -350,7 +751,7 @@ func (s *GossipStoreImpl) updateCluster(
 	}
 	for _, nodeId := range addNodeIds {
 		update, _ := peers[nodeId]
-		s.addNodeUnlocked(nodeId, types.NODE_STATUS_DOWN, update.QuorumMember, update.ClusterDomain)
+		s.addNodeUnlocked(nodeId, types.NODE_STATUS_DOWN, update.QuorumMember || update.Witness, update.ClusterDomain)
 	}
 
 	// Update quorum members
@@ -359,9 +760,19 @@ func (s *GossipStoreImpl) updateCluster(
 	for id, nodeInfo := range s.nodeMap {
 		update, ok := peers[id]
 		if ok {
-			nodeInfo.QuorumMember = update.QuorumMember
+			// Witnesses always vote in quorum
+			nodeInfo.QuorumMember = update.QuorumMember || update.Witness
 			nodeInfo.ClusterDomain = update.ClusterDomain
-			nodeInfo.Addr = update.Addr
+			nodeInfo.Weight = update.Weight
+			nodeInfo.Witness = update.Witness
+			if nodeInfo.Witness {
+				nodeInfo.Value = make(types.StoreMap)
+			}
+			if nodeInfo.Addr == "" || nodeInfo.Addr == nodeInfo.ConfiguredAddr {
+				// The node has not advertised another address
+				nodeInfo.Addr = update.Addr
+			}
+			nodeInfo.ConfiguredAddr = update.Addr
 			s.nodeMap[id] = nodeInfo
 			// Update this node's entry in the failure domain map
 			s.updateClusterDomainsMap(update.ClusterDomain, id)
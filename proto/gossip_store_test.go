@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -391,3 +392,35 @@ func TestGossipStoreBlackBoxTests(t *testing.T) {
 		}
 	}
 }
+
+func TestGossipStoreWitness(t *testing.T) {
+	printTestInfo()
+	g := NewGossipStore(ID, types.DEFAULT_GOSSIP_VERSION, DEFAULT_CLUSTER_ID, "")
+	g.InitStore(ID, types.DEFAULT_GOSSIP_VERSION, types.NODE_STATUS_UP, DEFAULT_CLUSTER_ID, "")
+	g.UpdateSelf(CPU, 10)
+
+	quorumMembersMap := g.updateCluster(map[types.NodeId]types.NodeUpdate{
+		ID:  {ClusterDomain: "zone0", QuorumMember: true},
+		"1": {ClusterDomain: "zone1", QuorumMember: true},
+		"2": {ClusterDomain: "witness", Witness: true},
+	})
+	require.Equal(t, 1, quorumMembersMap["witness"], "Expected witness to be a quorum member")
+
+	// Witnesses never become up and carry no store data
+	witness, err := g.GetLocalNodeInfo("2")
+	require.NoError(t, err)
+	require.True(t, witness.QuorumMember)
+	require.Equal(t, types.NODE_STATUS_WITNESS, aliveStatus(witness))
+	witness.Status = types.NODE_STATUS_WITNESS
+	witness.Value = types.StoreMap{CPU: 1}
+	witness.LastUpdateTs = time.Now()
+	g.nodeMap["2"] = witness
+	require.NotContains(t, g.GetStoreKeyValue(CPU), types.NodeId("2"))
+
+	// A witness does not store its own data
+	g.updateCluster(map[types.NodeId]types.NodeUpdate{
+		ID: {ClusterDomain: "witness", Witness: true},
+	})
+	g.UpdateSelf(MEMORY, 10)
+	require.Empty(t, g.GetStoreKeys())
+}
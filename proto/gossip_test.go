@@ -14,6 +14,9 @@ const (
 	TestQuorumTimeout time.Duration = 30 * time.Second
 )
 
+// startConfigOption sets the optional parts of the start configuration
+type startConfigOption func(config *types.GossipStartConfiguration)
+
 // New returns an initialized Gossip node
 // which identifies itself with the given ip
 func newGossiperImpl(
@@ -24,6 +27,7 @@ func newGossiperImpl(
 	version, clusterId string,
 	quorumProvider types.QuorumProvider,
 	activeMap types.ClusterDomainsActiveMap,
+	opts ...startConfigOption,
 ) (*GossiperImpl, error) {
 	g := new(GossiperImpl)
 	gi := types.GossipIntervals{
@@ -49,6 +53,9 @@ func newGossiperImpl(
 	}
 	startConfig.ActiveMap = activeMap
 	startConfig.QuorumProviderType = quorumProvider
+	for _, opt := range opts {
+		opt(&startConfig)
+	}
 	err := g.Start(startConfig)
 	return g, err
 }
@@ -84,7 +91,7 @@ func getNodeUpdateMap(nodesIp []string) map[types.NodeId]types.NodeUpdate {
 	peers := make(map[types.NodeId]types.NodeUpdate)
 	for i, ip := range nodesIp {
 		nodeId := types.NodeId(strconv.FormatInt(int64(i), 10))
-		peers[nodeId] = types.NodeUpdate{ip, true, ""}
+		peers[nodeId] = types.NodeUpdate{Addr: ip, QuorumMember: true, ClusterDomain: ""}
 	}
 	return peers
 }
@@ -222,9 +229,10 @@ func TestGossiperOnlyOneNodeGossips(t *testing.T) {
 			}
 		}
 		if nid != 0 {
-			if n.Status != types.NODE_STATUS_DOWN {
+			// The other nodes were stopped with a leave timeout
+			if n.Status != types.NODE_STATUS_LEFT {
 				t.Error("Gossiper ", nid,
-					"Expected node status to be down: ", nodeId, " n:", n.Status)
+					"Expected node status to be left: ", nodeId, " n:", n.Status)
 			}
 		}
 	}
@@ -294,6 +302,9 @@ func TestGossiperOneNodeNeverGossips(t *testing.T) {
 
 	for i, g := range gossipers {
 		res := g.GetStoreKeyValue(key)
+		if i == 0 && len(res) != len(nodes) {
+			t.Error("Gossiper ", i, "Expected all the nodes in its store. Got: ", res)
+		}
 		for nodeId, n := range res {
 			if nodeId != n.Id {
 				t.Error("Gossiper ", i, "Id does not match ",
@@ -303,10 +314,23 @@ func TestGossiperOneNodeNeverGossips(t *testing.T) {
 			if ok != nil {
 				t.Error("Failed to convert node to id ", nodeId, " n.Id", n.Id)
 			}
-			if nid == 0 {
-				if n.Status != types.NODE_STATUS_DOWN {
+			if i == 0 {
+				// Node 0 stopped gossiping. Its store is frozen as it was
+				// when it left, with itself marked as leaving.
+				nodeInfo, err := g.GetLocalNodeInfo(nodeId)
+				if err != nil {
+					t.Error("Gossiper ", i, "Failed to get node ", nodeId, ": ", err)
+				} else if nid == 0 && !nodeInfo.Leaving {
+					t.Error("Gossiper ", i, "Expected node ", nodeId, " to be leaving")
+				} else if nid != 0 && n.Status != types.NODE_STATUS_UP {
+					t.Error("Gossiper ", i, "Expected node", nodeId, " to be up. Got: ", n.Status)
+				}
+			} else if nid == 0 {
+				// Node 0 was stopped with a leave timeout, so it left
+				// gracefully
+				if n.Status != types.NODE_STATUS_LEFT {
 					t.Error("Gossiper ", i,
-						"Expected node status for ", nodeId, " to be down. Got: ", n.Status)
+						"Expected node status for ", nodeId, " to be left. Got: ", n.Status)
 				}
 			} else {
 				if n.Status != types.NODE_STATUS_UP {
@@ -411,9 +435,9 @@ func TestGossiperGroupingOfNodesWithSameVersion(t *testing.T) {
 	for i, ip := range nodes {
 		nodeId := types.NodeId(strconv.FormatInt(int64(i), 10))
 		if i != 0 && i%2 == 0 {
-			peers2[nodeId] = types.NodeUpdate{ip, true, ""}
+			peers2[nodeId] = types.NodeUpdate{Addr: ip, QuorumMember: true, ClusterDomain: ""}
 		} else {
-			peers1[nodeId] = types.NodeUpdate{ip, true, ""}
+			peers1[nodeId] = types.NodeUpdate{Addr: ip, QuorumMember: true, ClusterDomain: ""}
 		}
 	}
 
@@ -752,7 +776,7 @@ func TestGossiperAddNodeExternally(t *testing.T) {
 	}
 
 	nodes = append(nodes, "127.0.0.3:9160")
-	peers[types.NodeId("2")] = types.NodeUpdate{nodes[2], true, ""}
+	peers[types.NodeId("2")] = types.NodeUpdate{Addr: nodes[2], QuorumMember: true, ClusterDomain: ""}
 
 	for _, g := range gossipers {
 		g.UpdateCluster(peers)
@@ -969,9 +993,9 @@ func TestGossiperNodesWithDifferentClusterId(t *testing.T) {
 	for i, ip := range nodes {
 		nodeId := types.NodeId(strconv.FormatInt(int64(i), 10))
 		if i == 2 || i == 4 {
-			peers2[nodeId] = types.NodeUpdate{ip, true, ""}
+			peers2[nodeId] = types.NodeUpdate{Addr: ip, QuorumMember: true, ClusterDomain: ""}
 		} else {
-			peers1[nodeId] = types.NodeUpdate{ip, true, ""}
+			peers1[nodeId] = types.NodeUpdate{Addr: ip, QuorumMember: true, ClusterDomain: ""}
 		}
 	}
 
@@ -0,0 +1,39 @@
+package proto
+
+import (
+	"time"
+)
+
+// stateTimer is a cancellable timer of the state machine. It is not safe
+// for concurrent use and is only used by the state event handler.
+type stateTimer struct {
+	timer *time.Timer
+}
+
+// start starts the timer, cancelling it first if it is running
+func (t *stateTimer) start(d time.Duration) {
+	t.stop()
+	t.timer = time.NewTimer(d)
+}
+
+// stop cancels the timer. A timer which has fired but was not received
+// from is discarded.
+func (t *stateTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+func (t *stateTimer) running() bool {
+	return t.timer != nil
+}
+
+// C returns the channel on which the timer fires. It is nil if the timer
+// is not running, which blocks forever in a select.
+func (t *stateTimer) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
@@ -0,0 +1,221 @@
+package proto
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// parseGossipVersion returns the number of a gossip version of the form v<N>
+func parseGossipVersion(version string) (int, error) {
+	if !strings.HasPrefix(version, "v") {
+		return 0, fmt.Errorf("invalid gossip version %q", version)
+	}
+	n, err := strconv.Atoi(version[1:])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid gossip version %q", version)
+	}
+	return n, nil
+}
+
+// gossipVersionRange returns the range of gossip versions a node is
+// compatible with. Nodes which do not publish a range are only compatible
+// with their own version.
+func gossipVersionRange(version, minVersion, maxVersion string) (string, string) {
+	if minVersion == "" {
+		minVersion = version
+	}
+	if maxVersion == "" {
+		maxVersion = version
+	}
+	return minVersion, maxVersion
+}
+
+// validateGossipVersionRange checks that version lies in the given range
+func validateGossipVersionRange(version, minVersion, maxVersion string) error {
+	minVersion, maxVersion = gossipVersionRange(version, minVersion, maxVersion)
+	if minVersion == version && maxVersion == version {
+		return nil
+	}
+	v, err := parseGossipVersion(version)
+	if err != nil {
+		return err
+	}
+	minV, err := parseGossipVersion(minVersion)
+	if err != nil {
+		return err
+	}
+	maxV, err := parseGossipVersion(maxVersion)
+	if err != nil {
+		return err
+	}
+	if v < minV || v > maxV {
+		return fmt.Errorf("gossip version %v is not in the range [%v, %v]", version, minVersion, maxVersion)
+	}
+	return nil
+}
+
+// versionRange is a range of compatible gossip versions
+type versionRange struct {
+	min string
+	max string
+}
+
+// commonGossipVersion returns the highest gossip version which lies in all
+// the ranges
+func commonGossipVersion(ranges ...versionRange) (string, error) {
+	single := true
+	for _, r := range ranges {
+		if r.min != ranges[0].min || r.max != ranges[0].min {
+			single = false
+			break
+		}
+	}
+	if single {
+		// Same single version. It need not be of the form v<N>.
+		return ranges[0].min, nil
+	}
+
+	low, high := 0, math.MaxInt32
+	for _, r := range ranges {
+		minV, minErr := parseGossipVersion(r.min)
+		maxV, maxErr := parseGossipVersion(r.max)
+		if minErr != nil || maxErr != nil {
+			return "", fmt.Errorf("invalid gossip version range [%v, %v]", r.min, r.max)
+		}
+		if minV > low {
+			low = minV
+		}
+		if maxV < high {
+			high = maxV
+		}
+	}
+	if low > high {
+		return "", fmt.Errorf("no common gossip version")
+	}
+	return "v" + strconv.Itoa(high), nil
+}
+
+// negotiatePeerVersion returns the highest gossip version supported by both
+// this node and the peer which published the given meta data
+func (gd *GossipDelegate) negotiatePeerVersion(nodeMeta types.NodeMetaInfo) (string, error) {
+	ourMin, ourMax := gd.getGossipVersionRange()
+	theirMin, theirMax := gossipVersionRange(
+		nodeMeta.GossipVersion,
+		nodeMeta.MinGossipVersion,
+		nodeMeta.MaxGossipVersion,
+	)
+	return commonGossipVersion(versionRange{ourMin, ourMax}, versionRange{theirMin, theirMax})
+}
+
+// recordPeerVersion updates our view of the gossip version run by a peer
+func (gd *GossipDelegate) recordPeerVersion(nodeId types.NodeId, nodeMeta types.NodeMetaInfo) {
+	negotiated, err := gd.negotiatePeerVersion(nodeMeta)
+	if err != nil {
+		return
+	}
+	minVersion, maxVersion := gossipVersionRange(
+		nodeMeta.GossipVersion,
+		nodeMeta.MinGossipVersion,
+		nodeMeta.MaxGossipVersion,
+	)
+	gd.peerVersionsLock.Lock()
+	defer gd.peerVersionsLock.Unlock()
+	if gd.peerVersions == nil {
+		gd.peerVersions = make(map[types.NodeId]types.NodeVersion)
+	}
+	gd.peerVersions[nodeId] = types.NodeVersion{
+		GossipVersion:     nodeMeta.GossipVersion,
+		MinGossipVersion:  minVersion,
+		MaxGossipVersion:  maxVersion,
+		NegotiatedVersion: negotiated,
+	}
+}
+
+// peerNegotiatedVersion returns the gossip version negotiated with a peer,
+// if known
+func (gd *GossipDelegate) peerNegotiatedVersion(nodeId types.NodeId) (string, bool) {
+	gd.peerVersionsLock.Lock()
+	defer gd.peerVersionsLock.Unlock()
+	nodeVersion, ok := gd.peerVersions[nodeId]
+	return nodeVersion.NegotiatedVersion, ok
+}
+
+// knownGossipVersions returns the gossip versions which a memberlist node
+// name can end with: our version, the versions in our compatible range, the
+// versions run by the peers we heard from and the built-in versions
+func (gd *GossipDelegate) knownGossipVersions() []string {
+	versions := []string{gd.GetGossipVersion()}
+	minVersion, maxVersion := gd.getGossipVersionRange()
+	minV, minErr := parseGossipVersion(minVersion)
+	maxV, maxErr := parseGossipVersion(maxVersion)
+	if minErr == nil && maxErr == nil {
+		for v := minV; v <= maxV; v++ {
+			versions = append(versions, "v"+strconv.Itoa(v))
+		}
+	}
+	gd.peerVersionsLock.Lock()
+	for _, nodeVersion := range gd.peerVersions {
+		if nodeVersion.GossipVersion != "" {
+			versions = append(versions, nodeVersion.GossipVersion)
+		}
+	}
+	gd.peerVersionsLock.Unlock()
+	return append(versions, types.DEFAULT_GOSSIP_VERSION, types.GOSSIP_VERSION_2)
+}
+
+// peerGossipVersion returns the gossip version run by a peer, if known
+func (gd *GossipDelegate) peerGossipVersion(nodeId types.NodeId) (string, bool) {
+	gd.peerVersionsLock.Lock()
+	defer gd.peerVersionsLock.Unlock()
+	nodeVersion, ok := gd.peerVersions[nodeId]
+	return nodeVersion.GossipVersion, ok
+}
+
+// isStalePeerInstance returns true if the memberlist node name belongs to an
+// earlier instance of a peer, which has since joined with another gossip version
+func (gd *GossipDelegate) isStalePeerInstance(nodeName, memberlistName string) bool {
+	version, ok := gd.peerGossipVersion(types.NodeId(nodeName))
+	return ok && memberlistName != nodeName+version
+}
+
+// GetClusterVersions returns the gossip versions run by this node and by
+// the peers in the cluster it has heard from
+func (gd *GossipDelegate) GetClusterVersions() map[types.NodeId]types.NodeVersion {
+	nodeInfoMap := gd.GetLocalState()
+	minVersion, maxVersion := gd.getGossipVersionRange()
+	versions := map[types.NodeId]types.NodeVersion{
+		types.NodeId(gd.nodeId): {
+			GossipVersion:     gd.GetGossipVersion(),
+			MinGossipVersion:  minVersion,
+			MaxGossipVersion:  maxVersion,
+			NegotiatedVersion: gd.GetGossipVersion(),
+		},
+	}
+	gd.peerVersionsLock.Lock()
+	defer gd.peerVersionsLock.Unlock()
+	for id, nodeVersion := range gd.peerVersions {
+		if _, ok := nodeInfoMap[id]; ok {
+			versions[id] = nodeVersion
+		}
+	}
+	return versions
+}
+
+// GetClusterGossipVersion returns the highest gossip version supported by all
+// the nodes in the cluster view. It returns an empty string if the nodes do
+// not have a common version.
+func (gd *GossipDelegate) GetClusterGossipVersion() string {
+	ranges := []versionRange{}
+	for _, nodeVersion := range gd.GetClusterVersions() {
+		ranges = append(ranges, versionRange{nodeVersion.MinGossipVersion, nodeVersion.MaxGossipVersion})
+	}
+	version, err := commonGossipVersion(ranges...)
+	if err != nil {
+		return ""
+	}
+	return version
+}
@@ -0,0 +1,123 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommonGossipVersion(t *testing.T) {
+	printTestInfo()
+
+	tests := []struct {
+		ranges   []versionRange
+		expected string
+		fail     bool
+	}{
+		{[]versionRange{{"v2", "v2"}, {"v2", "v2"}}, "v2", false},
+		{[]versionRange{{"v1", "v1"}, {"v2", "v2"}}, "", true},
+		{[]versionRange{{"v1", "v2"}, {"v2", "v2"}}, "v2", false},
+		{[]versionRange{{"v1", "v2"}, {"v1", "v1"}}, "v1", false},
+		{[]versionRange{{"v1", "v3"}, {"v2", "v4"}, {"v1", "v2"}}, "v2", false},
+		{[]versionRange{{"v3", "v4"}, {"v1", "v2"}}, "", true},
+		{[]versionRange{{"custom", "custom"}, {"custom", "custom"}}, "custom", false},
+		{[]versionRange{{"custom", "custom"}, {"v1", "v2"}}, "", true},
+	}
+	for _, test := range tests {
+		version, err := commonGossipVersion(test.ranges...)
+		if test.fail {
+			require.Error(t, err, "Expected no common version for %v", test.ranges)
+			continue
+		}
+		require.NoError(t, err, "Unexpected error for %v", test.ranges)
+		require.Equal(t, test.expected, version, "Unexpected version for %v", test.ranges)
+	}
+
+	require.NoError(t, validateGossipVersionRange("v2", "", ""))
+	require.NoError(t, validateGossipVersionRange("v2", "v1", "v3"))
+	require.Error(t, validateGossipVersionRange("v2", "v3", "v4"))
+	require.Error(t, validateGossipVersionRange("v2", "x", "v3"))
+}
+
+func TestGossipDelegateVersionRange(t *testing.T) {
+	printTestInfo()
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	newNode := func(id types.NodeId, version, minVersion, maxVersion string) *memberlist.Node {
+		gs := NewGossipStore(id, version, DEFAULT_CLUSTER_ID, "")
+		gs.setGossipVersionRange(minVersion, maxVersion)
+		node := newTestMemberlistNodeWithMeta(t, id, gs.MetaInfo())
+		node.Name = string(id) + version
+		return node
+	}
+
+	// Default range only allows our own version
+	node1 := newNode("1", types.DEFAULT_GOSSIP_VERSION, "", "")
+	require.Error(t, gd.gossipChecks(node1), "Expected version mismatch")
+	node1 = newNode("1", types.DEFAULT_GOSSIP_VERSION, types.DEFAULT_GOSSIP_VERSION, types.GOSSIP_VERSION_2)
+	require.NoError(t, gd.gossipChecks(node1), "Expected compatible versions")
+	require.Equal(t, "1", gd.parseMemberlistNodeName(node1))
+
+	gd.NotifyJoin(node1)
+	versions := gd.GetClusterVersions()
+	require.Len(t, versions, 2)
+	require.Equal(t, types.NodeVersion{
+		GossipVersion:     types.DEFAULT_GOSSIP_VERSION,
+		MinGossipVersion:  types.DEFAULT_GOSSIP_VERSION,
+		MaxGossipVersion:  types.GOSSIP_VERSION_2,
+		NegotiatedVersion: types.GOSSIP_VERSION_2,
+	}, versions["1"])
+	require.Equal(t, types.GOSSIP_VERSION_2, gd.GetClusterGossipVersion())
+
+	// Node 1 gets upgraded. The leave of its old instance is ignored.
+	node1v2 := newNode("1", types.GOSSIP_VERSION_2, "", "")
+	gd.NotifyJoin(node1v2)
+	gd.UpdateNodeStatus("1", types.NODE_STATUS_UP)
+	gd.NotifyLeave(node1)
+	nodeInfo, err := gd.GetLocalNodeInfo("1")
+	require.NoError(t, err)
+	require.Equal(t, types.NODE_STATUS_UP, nodeInfo.Status, "Unexpected status for upgraded node")
+
+	// Our range must be widened to accept a node which only runs v1
+	gd.setGossipVersionRange(types.DEFAULT_GOSSIP_VERSION, types.GOSSIP_VERSION_2)
+	node2 := newNode("2", types.DEFAULT_GOSSIP_VERSION, "", "")
+	require.NoError(t, gd.gossipChecks(node2), "Expected compatible versions")
+	gd.NotifyJoin(node2)
+	require.Equal(t, types.DEFAULT_GOSSIP_VERSION, gd.GetClusterVersions()["2"].NegotiatedVersion)
+	// Node 1 only runs v2
+	require.Equal(t, "", gd.GetClusterGossipVersion())
+}
+
+func TestParseMemberlistNodeName(t *testing.T) {
+	printTestInfo()
+
+	gd := newTestGossipDelegate("0", getTestPeers(3), types.QUORUM_PROVIDER_DEFAULT)
+	newNode := func(id types.NodeId, version string) *memberlist.Node {
+		gs := NewGossipStore(id, version, DEFAULT_CLUSTER_ID, "")
+		node := newTestMemberlistNodeWithMeta(t, id, gs.MetaInfo())
+		node.Name = string(id) + version
+		return node
+	}
+
+	// The version is taken from the meta data
+	require.Equal(t, "nodev3", gd.parseMemberlistNodeName(newNode("nodev3", types.GOSSIP_VERSION_2)))
+	require.Equal(t, "nodev3", gd.parseMemberlistNodeName(newNode("nodev3", "v7")))
+	require.Equal(t, "node", gd.parseMemberlistNodeName(newNode("node", "custom")))
+
+	// Without meta data only the known versions are stripped
+	require.Equal(t, "nodev3", gd.parseMemberlistNodeName(&memberlist.Node{Name: "nodev3" + types.GOSSIP_VERSION_2}))
+	require.Equal(t, "nodev3", gd.parseMemberlistNodeName(&memberlist.Node{Name: "nodev3"}))
+}
+
+func TestGossiperPingNegotiatedVersion(t *testing.T) {
+	printTestInfo()
+
+	g := &GossiperImpl{}
+	g.peerVersions = map[types.NodeId]types.NodeVersion{
+		"1": {GossipVersion: types.DEFAULT_GOSSIP_VERSION, NegotiatedVersion: types.DEFAULT_GOSSIP_VERSION},
+	}
+	_, err := g.Ping("1", "127.0.0.1:9976")
+	require.Error(t, err, "Expected ping to be refused with gossip version v1")
+}
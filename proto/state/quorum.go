@@ -1,6 +1,7 @@
 package state
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/libopenstorage/gossip/types"
@@ -22,25 +23,139 @@ type Quorum interface {
 	Type() types.QuorumProvider
 }
 
+// QuorumProviderFactory creates the Quorum of a node as per the start
+// configuration of gossip
+type QuorumProviderFactory func(
+	selfId types.NodeId,
+	config types.GossipStartConfiguration,
+) (Quorum, error)
+
+var (
+	quorumProvidersLock sync.Mutex
+	quorumProviders     = map[types.QuorumProvider]QuorumProviderFactory{
+		types.QUORUM_PROVIDER_DEFAULT: func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+			return &defaultQuorum{selfId: selfId}, nil
+		},
+		types.QUORUM_PROVIDER_FAILURE_DOMAINS: func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+			return &failureDomainsQuorum{selfId: selfId}, nil
+		},
+		types.QUORUM_PROVIDER_NOOP: func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+			return &noopQuorumProvider{}, nil
+		},
+		types.QUORUM_PROVIDER_WEIGHTED: func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+			return &weightedQuorum{selfId: selfId}, nil
+		},
+		types.QUORUM_PROVIDER_ARBITER: func(selfId types.NodeId, config types.GossipStartConfiguration) (Quorum, error) {
+			if config.Arbiter == nil {
+				return nil, fmt.Errorf("gossip: Arbiter quorum provider requires an arbiter")
+			}
+			return NewArbiterQuorumProvider(selfId, config.Arbiter, config.ArbiterLeaseTTL), nil
+		},
+		types.QUORUM_PROVIDER_DYNAMIC: func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+			return &dynamicQuorum{
+				selfId:   selfId,
+				departed: make(map[types.NodeId]bool),
+			}, nil
+		},
+	}
+)
+
+// RegisterQuorumProvider registers the factory of a custom quorum provider.
+// The Quorum created by the factory should return the provider from Type.
+func RegisterQuorumProvider(provider types.QuorumProvider, factory QuorumProviderFactory) error {
+	if factory == nil {
+		return fmt.Errorf("gossip: Quorum provider factory for %v is nil", provider)
+	}
+	quorumProvidersLock.Lock()
+	defer quorumProvidersLock.Unlock()
+	if _, ok := quorumProviders[provider]; ok {
+		return fmt.Errorf("gossip: Quorum provider %v is already registered", provider)
+	}
+	quorumProviders[provider] = factory
+	return nil
+}
+
+// GetQuorumProvider returns the Quorum of the provider type selected by the
+// start configuration. It fails for provider types which are not registered.
+func GetQuorumProvider(
+	selfId types.NodeId,
+	config types.GossipStartConfiguration,
+) (Quorum, error) {
+	quorumProvidersLock.Lock()
+	factory, ok := quorumProviders[config.QuorumProviderType]
+	quorumProvidersLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("gossip: Unknown quorum provider %v", config.QuorumProviderType)
+	}
+	quorum, err := factory(selfId, config)
+	if err != nil {
+		return nil, err
+	}
+	if quorum == nil {
+		return nil, fmt.Errorf("gossip: Quorum provider %v returned no quorum", config.QuorumProviderType)
+	}
+	return quorum, nil
+}
+
 // NewQuorumProvider returns tan implementation of Quorum interface based on
-// the input type
+// the input type. It returns the failure domains quorum provider for types
+// which are not registered or need more configuration.
 func NewQuorumProvider(
 	selfId types.NodeId,
 	provider types.QuorumProvider,
 ) Quorum {
-	switch provider {
-	case types.QUORUM_PROVIDER_DEFAULT:
-		return &defaultQuorum{
-			selfId: selfId,
-		}
-	case types.QUORUM_PROVIDER_NOOP:
-		return &noopQuorumProvider{}
-	default:
+	quorum, err := GetQuorumProvider(selfId, types.GossipStartConfiguration{
+		QuorumProviderType: provider,
+	})
+	if err != nil {
 		// retains old behavior for function default
 		return &failureDomainsQuorum{
 			selfId: selfId,
 		}
 	}
+	return quorum
+}
+
+// isInMaintenance returns true if the node has been put in maintenance
+func isInMaintenance(nodeInfo types.NodeInfo) bool {
+	return nodeInfo.Maintenance
+}
+
+// isUp returns true if the node status counts towards quorum. A node which
+// is exiting maintenance still has the maintenance status and is counted.
+func isUp(nodeInfo types.NodeInfo) bool {
+	return nodeInfo.Status == types.NODE_STATUS_UP ||
+		nodeInfo.Status == types.NODE_STATUS_WITNESS ||
+		nodeInfo.Status == types.NODE_STATUS_NOT_IN_QUORUM ||
+		nodeInfo.Status == types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM ||
+		nodeInfo.Status == types.NODE_STATUS_MAINTENANCE
+}
+
+// isWitness returns true if the node is a quorum member which only acts as
+// a tie-breaker. Witnesses vote irrespective of the activation of their
+// failure domain.
+func isWitness(nodeInfo types.NodeInfo) bool {
+	return nodeInfo.QuorumMember && nodeInfo.Witness
+}
+
+// witnessVoteCounts returns true if the vote of a witness counts on our
+// side of a partition. A witness gives its vote to a single quorum member
+// at a time, so only the side which can see that member counts the vote.
+func witnessVoteCounts(localNodeInfoMap types.NodeInfoMap, nodeInfo types.NodeInfo) bool {
+	if nodeInfo.WitnessVote == "" {
+		return false
+	}
+	votedFor, ok := localNodeInfoMap[nodeInfo.WitnessVote]
+	return ok && !isWitness(votedFor) && isUp(votedFor)
+}
+
+// subtractMembers removes the excluded members from the total number of
+// quorum members
+func subtractMembers(total uint, excluded uint) uint {
+	if excluded > total {
+		return 0
+	}
+	return total - excluded
 }
 
 type defaultQuorum struct {
@@ -50,19 +165,34 @@ type defaultQuorum struct {
 }
 
 func (d *defaultQuorum) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap) bool {
+	return d.ExplainQuorum(localNodeInfoMap).InQuorum
+}
+
+func (d *defaultQuorum) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	upNodes := uint(0)
-	for _, nodeInfo := range localNodeInfoMap {
-		if nodeInfo.QuorumMember &&
-			(nodeInfo.Status == types.NODE_STATUS_UP ||
-				nodeInfo.Status == types.NODE_STATUS_NOT_IN_QUORUM ||
-				nodeInfo.Status == types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM) {
-			upNodes++
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_DEFAULT)
+	maintenanceNodes := uint(0)
+	for id, nodeInfo := range localNodeInfoMap {
+		if !nodeInfo.QuorumMember {
+			continue
 		}
+		if isInMaintenance(nodeInfo) {
+			// nodes in maintenance are neither required nor counted
+			maintenanceNodes++
+			ignoreMember(&e, id)
+			continue
+		}
+		countMember(&e, localNodeInfoMap, id, nodeInfo, 1)
+	}
+	for domain, domainQuorum := range e.Domains {
+		domainQuorum.Active = true
+		e.Domains[domain] = domainQuorum
 	}
-	quorum := (d.numQuorumMembers / 2) + 1
-	return upNodes >= quorum
+	e.TotalVotes = subtractMembers(d.numQuorumMembers, maintenanceNodes)
+	majorityVerdict(&e)
+	sortExplanation(&e)
+	return e
 }
 
 func (d *defaultQuorum) IsDomainActive(ipDomain string) bool {
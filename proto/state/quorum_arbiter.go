@@ -0,0 +1,179 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/sirupsen/logrus"
+)
+
+// arbiterQuorum is an implementation of Quorum which consults an external
+// arbiter when the number of up quorum members is at the majority boundary
+// or just below it. A node on the boundary is in quorum only while its side
+// of the cluster holds the arbiter lease.
+type arbiterQuorum struct {
+	numQuorumMembers uint
+	selfId           types.NodeId
+	arbiter          types.Arbiter
+	leaseTTL         time.Duration
+	// lastSide, lastAcquired and lastErr are the result of the last
+	// attempt to acquire the lease
+	lastSide     []string
+	lastAcquired bool
+	lastErr      error
+	lock         sync.Mutex
+}
+
+// NewArbiterQuorumProvider returns a Quorum which breaks ties with the
+// arbiter. The lease is held for leaseTTL unless renewed.
+func NewArbiterQuorumProvider(
+	selfId types.NodeId,
+	arbiter types.Arbiter,
+	leaseTTL time.Duration,
+) Quorum {
+	if leaseTTL == 0 {
+		leaseTTL = types.DEFAULT_ARBITER_LEASE_TTL
+	}
+	return &arbiterQuorum{
+		selfId:   selfId,
+		arbiter:  arbiter,
+		leaseTTL: leaseTTL,
+	}
+}
+
+// countVotes explains the verdict of the majority of the quorum members.
+// It returns the up members of our side of the cluster, and true if the
+// verdict is at the majority boundary and needs the arbiter.
+func (a *arbiterQuorum) countVotes(localNodeInfoMap types.NodeInfoMap) (types.QuorumExplanation, []string, bool) {
+	a.lock.Lock()
+	numQuorumMembers := a.numQuorumMembers
+	a.lock.Unlock()
+
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_ARBITER)
+	maintenanceNodes := uint(0)
+	for id, nodeInfo := range localNodeInfoMap {
+		if !nodeInfo.QuorumMember {
+			continue
+		}
+		if isInMaintenance(nodeInfo) {
+			// nodes in maintenance are neither required nor counted
+			maintenanceNodes++
+			ignoreMember(&e, id)
+			continue
+		}
+		countMember(&e, localNodeInfoMap, id, nodeInfo, 1)
+	}
+	for domain, domainQuorum := range e.Domains {
+		domainQuorum.Active = true
+		e.Domains[domain] = domainQuorum
+	}
+	e.TotalVotes = subtractMembers(numQuorumMembers, maintenanceNodes)
+	majorityVerdict(&e)
+	sortExplanation(&e)
+	// The nodes on our side of the cluster share the lease. Any of them
+	// renews it, so that the side keeps the lease while its members change.
+	side := make([]string, 0, len(e.UpMembers))
+	for _, id := range e.UpMembers {
+		side = append(side, string(id))
+	}
+	tie := e.UpVotes <= e.RequiredVotes && e.UpVotes+1 >= e.RequiredVotes && e.UpVotes > 0
+	return e, side, tie
+}
+
+func (a *arbiterQuorum) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap) bool {
+	e, side, tie := a.countVotes(localNodeInfoMap)
+	if !tie {
+		if e.InQuorum {
+			a.release(side)
+		}
+		return e.InQuorum
+	}
+
+	acquired, err := a.arbiter.Acquire(side, a.leaseTTL)
+	a.lock.Lock()
+	a.lastSide = side
+	a.lastAcquired = acquired
+	a.lastErr = err
+	a.lock.Unlock()
+	if err != nil {
+		logrus.Warnf("gossip: Unable to acquire lease from arbiter %v: %v", a.arbiter, err)
+		return e.InQuorum
+	}
+	if !acquired {
+		logrus.Infof("gossip: Arbiter %v lease is held by another side of the cluster", a.arbiter)
+	}
+	return acquired
+}
+
+// release gives up the lease held by our side once the side is in quorum
+// without it, so that the lease is free as soon as it is not needed
+func (a *arbiterQuorum) release(side []string) {
+	a.lock.Lock()
+	acquired := a.lastAcquired
+	a.lock.Unlock()
+	if !acquired {
+		return
+	}
+	if err := a.arbiter.Release(side); err != nil {
+		logrus.Warnf("gossip: Unable to release lease of arbiter %v: %v", a.arbiter, err)
+		return
+	}
+	logrus.Infof("gossip: Released lease of arbiter %v as the majority is in quorum", a.arbiter)
+	a.lock.Lock()
+	a.lastSide = nil
+	a.lastAcquired = false
+	a.lock.Unlock()
+}
+
+// ExplainQuorum reports the last verdict of the arbiter without consulting
+// it again
+func (a *arbiterQuorum) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
+	e, side, tie := a.countVotes(localNodeInfoMap)
+	if !tie {
+		return e
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if strings.Join(a.lastSide, ",") != strings.Join(side, ",") {
+		e.InQuorum = false
+		e.Reason = fmt.Sprintf("%v; arbiter %v has not been consulted for the side %v yet",
+			e.Reason, a.arbiter, side)
+	} else if a.lastErr != nil {
+		e.Reason = fmt.Sprintf("%v; arbiter %v failed: %v", e.Reason, a.arbiter, a.lastErr)
+	} else if a.lastAcquired {
+		e.InQuorum = true
+		e.Reason = fmt.Sprintf("%v; the side %v holds the lease of arbiter %v",
+			e.Reason, side, a.arbiter)
+	} else {
+		e.InQuorum = false
+		e.Reason = fmt.Sprintf("%v; another side holds the lease of arbiter %v", e.Reason, a.arbiter)
+	}
+	return e
+}
+
+func (a *arbiterQuorum) IsDomainActive(ipDomain string) bool {
+	// When no cluster domains are set, then the nodes are always active
+	return true
+}
+
+func (a *arbiterQuorum) UpdateNumOfQuorumMembers(quorumMemberMap types.ClusterDomainsQuorumMembersMap) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	numOfQuorumMembers := uint(0)
+	for _, quorumMembersInDomain := range quorumMemberMap {
+		numOfQuorumMembers = numOfQuorumMembers + uint(quorumMembersInDomain)
+	}
+	a.numQuorumMembers = numOfQuorumMembers
+}
+
+func (a *arbiterQuorum) UpdateClusterDomainsActiveMap(activeMap types.ClusterDomainsActiveMap) bool {
+	// no op
+	return false
+}
+
+func (a *arbiterQuorum) Type() types.QuorumProvider {
+	return types.QUORUM_PROVIDER_ARBITER
+}
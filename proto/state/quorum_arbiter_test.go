@@ -0,0 +1,81 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/pkg/arbiter"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArbiterQuorumTieBreak(t *testing.T) {
+	a := arbiter.NewMemoryArbiter()
+	newQuorum := func(selfId string) Quorum {
+		q := NewArbiterQuorumProvider(types.NodeId(selfId), a, 300*time.Millisecond)
+		q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{"": 4})
+		return q
+	}
+	q0 := newQuorum(nodes[0])
+	q2 := newQuorum(nodes[2])
+	require.Equal(t, types.QUORUM_PROVIDER_ARBITER, q0.Type())
+
+	// A clear majority does not need the arbiter
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 1, 1)
+	require.True(t, q0.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+
+	// The cluster is split in half. n0 and n1 can see each other and so
+	// can n2 and n3.
+	side0 := getWeightedNodeInfoMap(1, 1, 1, 1)
+	setNodeStatus(side0, nodes[2], types.NODE_STATUS_DOWN)
+	setNodeStatus(side0, nodes[3], types.NODE_STATUS_DOWN)
+	side1 := getWeightedNodeInfoMap(1, 1, 1, 1)
+	setNodeStatus(side1, nodes[0], types.NODE_STATUS_DOWN)
+	setNodeStatus(side1, nodes[1], types.NODE_STATUS_DOWN)
+
+	require.True(t, q0.IsNodeInQuorum(side0), "Expected side holding the lease in quorum")
+	require.True(t, newQuorum(nodes[1]).IsNodeInQuorum(side0), "Expected side holding the lease in quorum")
+	require.False(t, q2.IsNodeInQuorum(side1), "Expected other side not in quorum")
+
+	// The lease moves to the other side once it is not renewed
+	time.Sleep(400 * time.Millisecond)
+	require.True(t, q2.IsNodeInQuorum(side1), "Expected side holding the lease in quorum")
+	require.False(t, q0.IsNodeInQuorum(side0), "Expected other side not in quorum")
+
+	// Well below the majority the arbiter is not consulted
+	setNodeStatus(side1, nodes[3], types.NODE_STATUS_DOWN)
+	require.False(t, q2.IsNodeInQuorum(side1), "Expected node not in quorum")
+}
+
+func TestArbiterQuorumSideChanges(t *testing.T) {
+	a := arbiter.NewMemoryArbiter()
+	newQuorum := func(selfId string) Quorum {
+		q := NewArbiterQuorumProvider(types.NodeId(selfId), a, time.Hour)
+		q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{"": 5})
+		return q
+	}
+	q1 := newQuorum(nodes[1])
+	q3 := newQuorum(nodes[3])
+
+	// n0, n1 and n2 are split from n3 and n4
+	side0 := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	setNodeStatus(side0, nodes[3], types.NODE_STATUS_DOWN)
+	setNodeStatus(side0, nodes[4], types.NODE_STATUS_DOWN)
+	side1 := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	setNodeStatus(side1, nodes[0], types.NODE_STATUS_DOWN)
+	setNodeStatus(side1, nodes[1], types.NODE_STATUS_DOWN)
+	setNodeStatus(side1, nodes[2], types.NODE_STATUS_DOWN)
+	require.True(t, q1.IsNodeInQuorum(side0), "Expected side holding the lease in quorum")
+	require.False(t, q3.IsNodeInQuorum(side1), "Expected other side not in quorum")
+
+	// The side keeps the lease when one of its members goes down
+	setNodeStatus(side0, nodes[0], types.NODE_STATUS_DOWN)
+	require.True(t, q1.IsNodeInQuorum(side0), "Expected side holding the lease in quorum")
+	require.True(t, q1.(QuorumExplainer).ExplainQuorum(side0).InQuorum, "Expected side holding the lease in quorum")
+	require.False(t, q3.IsNodeInQuorum(side1), "Expected other side not in quorum")
+
+	// The lease is released once the side is in quorum without it
+	healed := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	require.True(t, q1.IsNodeInQuorum(healed), "Expected node in quorum")
+	require.True(t, q3.IsNodeInQuorum(side1), "Expected released lease to be acquired")
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/sirupsen/logrus"
+)
+
+// dynamicQuorum is an implementation of Quorum in which the quorum members
+// that leave the cluster gracefully are no more required for quorum. A
+// departure is accepted if the departing node along with the up nodes form
+// a majority of the remaining members. Nodes which fail keep counting
+// against quorum.
+type dynamicQuorum struct {
+	numQuorumMembers uint
+	selfId           types.NodeId
+	// departed are the quorum members whose departure was accepted
+	departed map[types.NodeId]bool
+	lock     sync.Mutex
+}
+
+func (d *dynamicQuorum) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.evaluate(localNodeInfoMap, d.departed, true).InQuorum
+}
+
+// ExplainQuorum explains the verdict without accepting any departures
+func (d *dynamicQuorum) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	departed := make(map[types.NodeId]bool, len(d.departed))
+	for id := range d.departed {
+		departed[id] = true
+	}
+	return d.evaluate(localNodeInfoMap, departed, false)
+}
+
+// evaluate updates the departed nodes as per the node info map and returns
+// the explanation of the verdict
+func (d *dynamicQuorum) evaluate(
+	localNodeInfoMap types.NodeInfoMap,
+	departed map[types.NodeId]bool,
+	logDepartures bool,
+) types.QuorumExplanation {
+	for id := range departed {
+		if nodeInfo, ok := localNodeInfoMap[id]; !ok || nodeInfo.Status != types.NODE_STATUS_LEFT {
+			// The node was removed from the cluster or has come back
+			delete(departed, id)
+		}
+	}
+
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_DYNAMIC)
+	maintenanceNodes := uint(0)
+	leftNodes := []types.NodeId{}
+	for id, nodeInfo := range localNodeInfoMap {
+		if !nodeInfo.QuorumMember {
+			continue
+		}
+		if isInMaintenance(nodeInfo) {
+			// nodes in maintenance are neither required nor counted
+			maintenanceNodes++
+			ignoreMember(&e, id)
+		} else if departed[id] {
+			ignoreMember(&e, id)
+		} else if nodeInfo.Status == types.NODE_STATUS_LEFT {
+			leftNodes = append(leftNodes, id)
+		} else {
+			countMember(&e, localNodeInfoMap, id, nodeInfo, 1)
+		}
+	}
+	e.TotalVotes = subtractMembers(d.numQuorumMembers, maintenanceNodes+uint(len(departed)))
+
+	// Accept the departures in the same order on all the nodes
+	sortNodeIds(leftNodes)
+	for _, id := range leftNodes {
+		if e.UpVotes+1 < (e.TotalVotes/2)+1 {
+			// The departure is not accepted and the node is missing
+			countMember(&e, localNodeInfoMap, id, localNodeInfoMap[id], 1)
+			continue
+		}
+		departed[id] = true
+		e.TotalVotes = subtractMembers(e.TotalVotes, 1)
+		ignoreMember(&e, id)
+		if logDepartures {
+			logrus.Infof("gossip: Node %v left gracefully and is no more a part of quorum", id)
+		}
+	}
+	for domain, domainQuorum := range e.Domains {
+		domainQuorum.Active = true
+		e.Domains[domain] = domainQuorum
+	}
+	majorityVerdict(&e)
+	sortExplanation(&e)
+	return e
+}
+
+func (d *dynamicQuorum) IsDomainActive(ipDomain string) bool {
+	// When no cluster domains are set, then the nodes are always active
+	return true
+}
+
+func (d *dynamicQuorum) UpdateNumOfQuorumMembers(quorumMemberMap types.ClusterDomainsQuorumMembersMap) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	numOfQuorumMembers := uint(0)
+	for _, quorumMembersInDomain := range quorumMemberMap {
+		numOfQuorumMembers = numOfQuorumMembers + uint(quorumMembersInDomain)
+	}
+	d.numQuorumMembers = numOfQuorumMembers
+}
+
+func (d *dynamicQuorum) UpdateClusterDomainsActiveMap(activeMap types.ClusterDomainsActiveMap) bool {
+	// no op
+	return false
+}
+
+func (d *dynamicQuorum) Type() types.QuorumProvider {
+	return types.QUORUM_PROVIDER_DYNAMIC
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicQuorumScaleDown(t *testing.T) {
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_DYNAMIC)
+	q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{"": 5})
+	require.Equal(t, types.QUORUM_PROVIDER_DYNAMIC, q.Type())
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+
+	// The cluster is scaled down one node at a time till only n0 is left
+	for i := 4; i > 0; i-- {
+		setNodeStatus(localNodeInfoMap, nodes[i], types.NODE_STATUS_LEFT)
+		require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum after %v left", nodes[i])
+	}
+
+	// A node which comes back is required for quorum again
+	setNodeStatus(localNodeInfoMap, nodes[1], types.NODE_STATUS_DOWN)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+}
+
+func TestDynamicQuorumFailures(t *testing.T) {
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_DYNAMIC)
+	q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{"": 5})
+
+	// Failed nodes keep counting against quorum
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[4], types.NODE_STATUS_DOWN)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_DOWN)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// A departure is not accepted without a majority
+	setNodeStatus(localNodeInfoMap, nodes[1], types.NODE_STATUS_LEFT)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// The departure is accepted once the failed nodes are back
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_UP)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_UP)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+	setNodeStatus(localNodeInfoMap, nodes[4], types.NODE_STATUS_LEFT)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum with 2 of 3 members up")
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/libopenstorage/gossip/types"
+)
+
+// QuorumExplainer is implemented by the quorum providers which can explain
+// their verdict. ExplainQuorum does not change the state of the provider.
+type QuorumExplainer interface {
+	ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation
+}
+
+func newQuorumExplanation(provider types.QuorumProvider) types.QuorumExplanation {
+	return types.QuorumExplanation{
+		Provider:        provider,
+		Domains:         make(map[string]types.DomainQuorum),
+		InactiveDomains: []string{},
+		UpMembers:       []types.NodeId{},
+		MissingMembers:  make(map[types.NodeId]types.NodeStatus),
+		IgnoredMembers:  []types.NodeId{},
+	}
+}
+
+// countMember counts the votes of a quorum member as up or missing. The
+// votes of a witness are only up if the witness votes for our side.
+func countMember(
+	e *types.QuorumExplanation,
+	localNodeInfoMap types.NodeInfoMap,
+	id types.NodeId,
+	nodeInfo types.NodeInfo,
+	votes uint,
+) {
+	domain := e.Domains[nodeInfo.ClusterDomain]
+	domain.TotalVotes += votes
+	if isUp(nodeInfo) && (!isWitness(nodeInfo) || witnessVoteCounts(localNodeInfoMap, nodeInfo)) {
+		domain.UpVotes += votes
+		e.UpVotes += votes
+		e.UpMembers = append(e.UpMembers, id)
+	} else {
+		e.MissingMembers[id] = nodeInfo.Status
+	}
+	e.Domains[nodeInfo.ClusterDomain] = domain
+}
+
+// ignoreMember records a quorum member which is neither required nor counted
+func ignoreMember(e *types.QuorumExplanation, id types.NodeId) {
+	e.IgnoredMembers = append(e.IgnoredMembers, id)
+}
+
+// majorityVerdict sets the required votes to a majority of the total votes
+// and the verdict accordingly
+func majorityVerdict(e *types.QuorumExplanation) {
+	e.RequiredVotes = (e.TotalVotes / 2) + 1
+	for name, domain := range e.Domains {
+		otherUpVotes := e.UpVotes - domain.UpVotes
+		domain.RequiredVotes = 0
+		if otherUpVotes < e.RequiredVotes {
+			domain.RequiredVotes = e.RequiredVotes - otherUpVotes
+		}
+		e.Domains[name] = domain
+	}
+	e.InQuorum = e.UpVotes >= e.RequiredVotes
+	e.Reason = fmt.Sprintf("%v of %v votes are up and %v are required",
+		e.UpVotes, e.TotalVotes, e.RequiredVotes)
+}
+
+// sortExplanation orders the lists of the explanation
+func sortExplanation(e *types.QuorumExplanation) {
+	sort.Strings(e.InactiveDomains)
+	sortNodeIds(e.UpMembers)
+	sortNodeIds(e.IgnoredMembers)
+}
+
+func sortNodeIds(ids []types.NodeId) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
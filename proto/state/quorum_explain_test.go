@@ -0,0 +1,112 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/gossip/pkg/arbiter"
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainQuorumFailureDomains(t *testing.T) {
+	localNodeInfoMap := getDefaultNodeInfoMap(false)
+	setNodeStatus(localNodeInfoMap, nodes[1], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_SUSPECT_DOWN)
+	nodeInfo := localNodeInfoMap[types.NodeId(nodes[5])]
+	nodeInfo.Maintenance = true
+	localNodeInfoMap[types.NodeId(nodes[5])] = nodeInfo
+
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_FAILURE_DOMAINS)
+	q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{
+		zones[0]: 2,
+		zones[1]: 2,
+		zones[2]: 2,
+	})
+	q.UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap{
+		zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		zones[1]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		zones[2]: types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	})
+
+	e := q.(QuorumExplainer).ExplainQuorum(localNodeInfoMap)
+	require.Equal(t, q.IsNodeInQuorum(localNodeInfoMap), e.InQuorum)
+	require.False(t, e.InQuorum, "Expected node not in quorum")
+	require.Equal(t, types.QUORUM_PROVIDER_FAILURE_DOMAINS, e.Provider)
+	require.Equal(t, uint(4), e.TotalVotes)
+	require.Equal(t, uint(3), e.RequiredVotes)
+	require.Equal(t, uint(2), e.UpVotes)
+	require.Equal(t, []types.NodeId{"n0", "n4"}, e.UpMembers)
+	require.Equal(t, map[types.NodeId]types.NodeStatus{
+		"n1": types.NODE_STATUS_DOWN,
+		"n3": types.NODE_STATUS_SUSPECT_DOWN,
+	}, e.MissingMembers)
+	require.Equal(t, []types.NodeId{"n2", "n5"}, e.IgnoredMembers)
+	require.Equal(t, []string{zones[2]}, e.InactiveDomains)
+	require.Equal(t, types.DomainQuorum{Active: true, TotalVotes: 2, UpVotes: 1, RequiredVotes: 2}, e.Domains[zones[0]])
+	require.Equal(t, types.DomainQuorum{Active: true, TotalVotes: 2, UpVotes: 1, RequiredVotes: 2}, e.Domains[zones[1]])
+	require.NotContains(t, e.Domains, zones[2])
+}
+
+func TestExplainQuorumDoesNotChangeState(t *testing.T) {
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_DYNAMIC)
+	q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{"": 5})
+	setNodeStatus(localNodeInfoMap, nodes[4], types.NODE_STATUS_LEFT)
+
+	// The explanation accepts the departure without recording it
+	e := q.(QuorumExplainer).ExplainQuorum(localNodeInfoMap)
+	require.True(t, e.InQuorum, "Expected node in quorum")
+	require.Equal(t, uint(4), e.TotalVotes)
+	require.Equal(t, []types.NodeId{"n4"}, e.IgnoredMembers)
+
+	setNodeStatus(localNodeInfoMap, nodes[1], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	e = q.(QuorumExplainer).ExplainQuorum(localNodeInfoMap)
+	require.False(t, e.InQuorum, "Expected node not in quorum")
+	require.Equal(t, uint(5), e.TotalVotes)
+	require.Equal(t, types.NODE_STATUS_LEFT, e.MissingMembers["n4"])
+}
+
+func TestExplainQuorumDomainRequiredVotes(t *testing.T) {
+	// zone0 has n0, n2 and n4. zone1 has n1 and n3.
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 1, 1, 1)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[4], types.NODE_STATUS_DOWN)
+
+	for _, provider := range []types.QuorumProvider{
+		types.QUORUM_PROVIDER_DEFAULT,
+		types.QUORUM_PROVIDER_FAILURE_DOMAINS,
+		types.QUORUM_PROVIDER_WEIGHTED,
+		types.QUORUM_PROVIDER_ARBITER,
+		types.QUORUM_PROVIDER_DYNAMIC,
+	} {
+		var q Quorum
+		if provider == types.QUORUM_PROVIDER_ARBITER {
+			q = NewArbiterQuorumProvider(types.NodeId(nodes[0]), arbiter.NewMemoryArbiter(), time.Hour)
+		} else {
+			q = NewQuorumProvider(types.NodeId(nodes[0]), provider)
+		}
+		q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{
+			zones[0]: 3,
+			zones[1]: 2,
+		})
+		q.UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap{
+			zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+			zones[1]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		})
+
+		// 3 of 5 votes are required. zone1 has 1 up vote, so zone0 needs
+		// 2 of its up votes and zone1 needs its only up vote. The arbiter
+		// is consulted at the majority boundary.
+		require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum with provider %v", provider)
+		e := q.(QuorumExplainer).ExplainQuorum(localNodeInfoMap)
+		require.True(t, e.InQuorum, "Expected node in quorum with provider %v", provider)
+		require.Equal(t, uint(3), e.RequiredVotes, "Unexpected required votes with provider %v", provider)
+		require.Equal(t, types.DomainQuorum{Active: true, TotalVotes: 3, UpVotes: 2, RequiredVotes: 2},
+			e.Domains[zones[0]], "Unexpected votes of %v with provider %v", zones[0], provider)
+		require.Equal(t, types.DomainQuorum{Active: true, TotalVotes: 2, UpVotes: 1, RequiredVotes: 1},
+			e.Domains[zones[1]], "Unexpected votes of %v with provider %v", zones[1], provider)
+	}
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/libopenstorage/gossip/types"
@@ -17,47 +18,70 @@ type failureDomainsQuorum struct {
 }
 
 func (f *failureDomainsQuorum) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap) bool {
+	return f.ExplainQuorum(localNodeInfoMap).InQuorum
+}
+
+func (f *failureDomainsQuorum) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	selfNodeInfo := localNodeInfoMap[f.selfId]
-	selfDomain := selfNodeInfo.ClusterDomain
-
-	if !f.isDomainActive(selfDomain) {
-		// This node is a part of deactivated failure domain
-		// Shoot ourselves down as we are not in quorum
-		return false
-	}
-
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_FAILURE_DOMAINS)
 	totalNodesInActiveDomains := uint(0)
 	for domainName, isActive := range f.activeMap {
 		if isActive != types.CLUSTER_DOMAIN_STATE_ACTIVE {
+			e.InactiveDomains = append(e.InactiveDomains, domainName)
 			continue
 		}
 		quorumCount := f.quorumMembersMap[domainName]
 		totalNodesInActiveDomains = totalNodesInActiveDomains + uint(quorumCount)
+		e.Domains[domainName] = types.DomainQuorum{Active: true}
 	}
-	upNodesInActiveDomains := uint(0)
+	maintenanceNodesInActiveDomains := uint(0)
 
-	for _, nodeInfo := range localNodeInfoMap {
+	for id, nodeInfo := range localNodeInfoMap {
 		if nodeInfo.QuorumMember {
-			if !f.isDomainActive(nodeInfo.ClusterDomain) {
+			if isWitness(nodeInfo) && !f.isDomainActive(nodeInfo.ClusterDomain) {
+				// witnesses outside the active domains still break ties
+				totalNodesInActiveDomains++
+			} else if !f.isDomainActive(nodeInfo.ClusterDomain) {
 				// node is not a part of active domain
 				// do not consider in quorum calculations
+				ignoreMember(&e, id)
 				continue
 			}
 
-			if nodeInfo.Status == types.NODE_STATUS_UP ||
-				nodeInfo.Status == types.NODE_STATUS_NOT_IN_QUORUM ||
-				nodeInfo.Status == types.NODE_STATUS_SUSPECT_NOT_IN_QUORUM {
-				upNodesInActiveDomains++
+			if isInMaintenance(nodeInfo) {
+				// nodes in maintenance are neither required nor counted
+				maintenanceNodesInActiveDomains++
+				ignoreMember(&e, id)
+				continue
 			}
+
+			countMember(&e, localNodeInfoMap, id, nodeInfo, 1)
+		}
+	}
+	// The members of the active domains which we have not heard of are
+	// also required
+	for domainName, domainQuorum := range e.Domains {
+		if domainQuorum.Active {
+			domainQuorum.TotalVotes = uint(f.quorumMembersMap[domainName])
+			e.Domains[domainName] = domainQuorum
 		}
 	}
 
 	// Check if we are in quorum
-	quorumCount := (totalNodesInActiveDomains / 2) + 1
-	return upNodesInActiveDomains >= quorumCount
+	e.TotalVotes = subtractMembers(totalNodesInActiveDomains, maintenanceNodesInActiveDomains)
+	majorityVerdict(&e)
+	sortExplanation(&e)
+
+	selfNodeInfo := localNodeInfoMap[f.selfId]
+	if !f.isDomainActive(selfNodeInfo.ClusterDomain) && !isWitness(selfNodeInfo) {
+		// This node is a part of deactivated failure domain
+		// Shoot ourselves down as we are not in quorum
+		e.InQuorum = false
+		e.Reason = fmt.Sprintf("cluster domain %v of this node is not active", selfNodeInfo.ClusterDomain)
+	}
+	return e
 }
 
 // isDomainActive returns true if the input failure domains is a part of
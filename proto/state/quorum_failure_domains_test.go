@@ -324,3 +324,152 @@ func TestQuorumProviderNodesNeverGossiped(t *testing.T) {
 		require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
 	}
 }
+
+func TestQuorumProviderNodesInMaintenance(t *testing.T) {
+	// All zones active
+	// 3 nodes in maintenance and offline
+	// All quorum members
+	localNodeInfoMap := getDefaultNodeInfoMap(false)
+	for i := 0; i < 3; i++ {
+		nodeInfo := localNodeInfoMap[types.NodeId(nodes[i])]
+		nodeInfo.Status = types.NODE_STATUS_DOWN
+		nodeInfo.Maintenance = true
+		localNodeInfoMap[types.NodeId(nodes[i])] = nodeInfo
+	}
+
+	for _, provider := range []types.QuorumProvider{
+		types.QUORUM_PROVIDER_DEFAULT,
+		types.QUORUM_PROVIDER_FAILURE_DOMAINS,
+	} {
+		q := NewQuorumProvider(types.NodeId(nodes[3]), provider)
+		q.UpdateNumOfQuorumMembers(
+			types.ClusterDomainsQuorumMembersMap(
+				map[string]int{
+					zones[0]: 2,
+					zones[1]: 2,
+					zones[2]: 2,
+				},
+			),
+		)
+		q.UpdateClusterDomainsActiveMap(
+			types.ClusterDomainsActiveMap(
+				map[string]types.ClusterDomainState{
+					zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+					zones[1]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+					zones[2]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+				},
+			),
+		)
+		require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum for provider %v", provider)
+
+		// Two more nodes go offline. 1 out of the 3 remaining nodes
+		// is not a majority.
+		nodeInfo := localNodeInfoMap[types.NodeId(nodes[4])]
+		nodeInfo.Status = types.NODE_STATUS_DOWN
+		localNodeInfoMap[types.NodeId(nodes[4])] = nodeInfo
+		nodeInfo = localNodeInfoMap[types.NodeId(nodes[5])]
+		nodeInfo.Status = types.NODE_STATUS_DOWN
+		localNodeInfoMap[types.NodeId(nodes[5])] = nodeInfo
+		require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum for provider %v", provider)
+
+		nodeInfo = localNodeInfoMap[types.NodeId(nodes[4])]
+		nodeInfo.Status = types.NODE_STATUS_UP
+		localNodeInfoMap[types.NodeId(nodes[4])] = nodeInfo
+		nodeInfo = localNodeInfoMap[types.NodeId(nodes[5])]
+		nodeInfo.Status = types.NODE_STATUS_UP
+		localNodeInfoMap[types.NodeId(nodes[5])] = nodeInfo
+	}
+}
+
+func TestQuorumProviderWitness(t *testing.T) {
+	// n0 and n1 are in zone0, n2 and n3 are in zone1 and the witness n4
+	// is in zone2 which is not a workload domain. The witness votes for n0.
+	localNodeInfoMap := make(types.NodeInfoMap)
+	for i := 0; i < 5; i++ {
+		nodeInfo := types.NodeInfo{
+			ClusterDomain: zones[i/2],
+			QuorumMember:  true,
+			Status:        types.NODE_STATUS_UP,
+		}
+		if i == 4 {
+			nodeInfo.Witness = true
+			nodeInfo.Status = types.NODE_STATUS_WITNESS
+			nodeInfo.WitnessVote = types.NodeId(nodes[0])
+		}
+		localNodeInfoMap[types.NodeId(nodes[i])] = nodeInfo
+	}
+	activeMap := types.ClusterDomainsActiveMap{
+		zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		zones[1]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+	}
+	newQuorum := func(selfId string, provider types.QuorumProvider) Quorum {
+		q := NewQuorumProvider(types.NodeId(selfId), provider)
+		q.UpdateNumOfQuorumMembers(types.ClusterDomainsQuorumMembersMap{
+			zones[0]: 2,
+			zones[1]: 2,
+			zones[2]: 1,
+		})
+		q.UpdateClusterDomainsActiveMap(activeMap)
+		return q
+	}
+	// splitViews returns the views of zone0 and zone1 when the inter-site
+	// link is down
+	splitViews := func(witnessStatusInZone1 types.NodeStatus) (types.NodeInfoMap, types.NodeInfoMap) {
+		zone0View := make(types.NodeInfoMap)
+		zone1View := make(types.NodeInfoMap)
+		for id, nodeInfo := range localNodeInfoMap {
+			zone0View[id] = nodeInfo
+			zone1View[id] = nodeInfo
+			switch nodeInfo.ClusterDomain {
+			case zones[1]:
+				nodeInfo.Status = types.NODE_STATUS_DOWN
+				zone0View[id] = nodeInfo
+			case zones[0]:
+				nodeInfo.Status = types.NODE_STATUS_DOWN
+				zone1View[id] = nodeInfo
+			default:
+				nodeInfo.Status = witnessStatusInZone1
+				zone1View[id] = nodeInfo
+			}
+		}
+		return zone0View, zone1View
+	}
+
+	for _, provider := range []types.QuorumProvider{
+		types.QUORUM_PROVIDER_FAILURE_DOMAINS,
+		types.QUORUM_PROVIDER_WEIGHTED,
+	} {
+		// zone0 can still see the witness
+		zone0View, zone1View := splitViews(types.NODE_STATUS_DOWN)
+		require.True(t, newQuorum(nodes[0], provider).IsNodeInQuorum(zone0View), "Expected zone0 in quorum")
+		require.True(t, newQuorum(nodes[4], provider).IsNodeInQuorum(zone0View), "Expected witness in quorum")
+		require.False(t, newQuorum(nodes[2], provider).IsNodeInQuorum(zone1View), "Expected zone1 not in quorum")
+
+		// Both the zones can still see the witness. Only the zone with
+		// the node the witness votes for counts its vote.
+		zone0View, zone1View = splitViews(types.NODE_STATUS_WITNESS)
+		require.True(t, newQuorum(nodes[0], provider).IsNodeInQuorum(zone0View), "Expected zone0 in quorum")
+		require.False(t, newQuorum(nodes[2], provider).IsNodeInQuorum(zone1View), "Expected zone1 not in quorum")
+		e := newQuorum(nodes[2], provider).(QuorumExplainer).ExplainQuorum(zone1View)
+		require.Equal(t, types.NODE_STATUS_WITNESS, e.MissingMembers[types.NodeId(nodes[4])])
+
+		// The witness moved its vote to zone1
+		witness := localNodeInfoMap[types.NodeId(nodes[4])]
+		witness.WitnessVote = types.NodeId(nodes[2])
+		localNodeInfoMap[types.NodeId(nodes[4])] = witness
+		zone0View, zone1View = splitViews(types.NODE_STATUS_WITNESS)
+		require.False(t, newQuorum(nodes[0], provider).IsNodeInQuorum(zone0View), "Expected zone0 not in quorum")
+		require.True(t, newQuorum(nodes[2], provider).IsNodeInQuorum(zone1View), "Expected zone1 in quorum")
+
+		// A witness which has not voted yet does not count on either side
+		witness.WitnessVote = ""
+		localNodeInfoMap[types.NodeId(nodes[4])] = witness
+		zone0View, zone1View = splitViews(types.NODE_STATUS_WITNESS)
+		require.False(t, newQuorum(nodes[0], provider).IsNodeInQuorum(zone0View), "Expected zone0 not in quorum")
+		require.False(t, newQuorum(nodes[2], provider).IsNodeInQuorum(zone1View), "Expected zone1 not in quorum")
+		require.True(t, newQuorum(nodes[0], provider).IsNodeInQuorum(localNodeInfoMap), "Expected all nodes in quorum")
+
+		witness.WitnessVote = types.NodeId(nodes[0])
+		localNodeInfoMap[types.NodeId(nodes[4])] = witness
+	}
+}
@@ -14,6 +14,19 @@ func (d *noopQuorumProvider) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap)
 	return true
 }
 
+func (d *noopQuorumProvider) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_NOOP)
+	for id, nodeInfo := range localNodeInfoMap {
+		if nodeInfo.QuorumMember {
+			ignoreMember(&e, id)
+		}
+	}
+	sortExplanation(&e)
+	e.InQuorum = true
+	e.Reason = "quorum is not required"
+	return e
+}
+
 func (d *noopQuorumProvider) IsDomainActive(ipDomain string) bool {
 	// domain agnostic
 	return true
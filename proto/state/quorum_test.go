@@ -0,0 +1,45 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testQuorumProvider = types.QuorumProvider(100)
+
+type testQuorum struct {
+	noopQuorumProvider
+}
+
+func (t *testQuorum) Type() types.QuorumProvider {
+	return testQuorumProvider
+}
+
+func TestRegisterQuorumProvider(t *testing.T) {
+	config := types.GossipStartConfiguration{QuorumProviderType: testQuorumProvider}
+	_, err := GetQuorumProvider(types.NodeId(nodes[0]), config)
+	require.Error(t, err, "Expected error for unknown quorum provider")
+	// The old behavior is retained for unknown types
+	q := NewQuorumProvider(types.NodeId(nodes[0]), testQuorumProvider)
+	require.Equal(t, types.QUORUM_PROVIDER_FAILURE_DOMAINS, q.Type())
+
+	factory := func(selfId types.NodeId, _ types.GossipStartConfiguration) (Quorum, error) {
+		return &testQuorum{}, nil
+	}
+	require.NoError(t, RegisterQuorumProvider(testQuorumProvider, factory))
+	require.Error(t, RegisterQuorumProvider(testQuorumProvider, factory), "Expected error registering twice")
+	require.Error(t, RegisterQuorumProvider(types.QUORUM_PROVIDER_DEFAULT, factory), "Expected error replacing a built-in provider")
+
+	q, err = GetQuorumProvider(types.NodeId(nodes[0]), config)
+	require.NoError(t, err, "Failed to get registered quorum provider")
+	require.Equal(t, testQuorumProvider, q.Type())
+	require.Equal(t, testQuorumProvider, NewQuorumProvider(types.NodeId(nodes[0]), testQuorumProvider).Type())
+
+	// Built-in providers which need configuration fail without it
+	_, err = GetQuorumProvider(types.NodeId(nodes[0]), types.GossipStartConfiguration{
+		QuorumProviderType: types.QUORUM_PROVIDER_ARBITER,
+	})
+	require.Error(t, err, "Expected error without an arbiter")
+}
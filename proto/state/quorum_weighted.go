@@ -0,0 +1,117 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/sirupsen/logrus"
+)
+
+// weightedQuorum is an implementation of Quorum in which every quorum member
+// carries a vote weight. A node is in quorum if the nodes it can see hold a
+// strict majority of the total weight of the active failure domains.
+type weightedQuorum struct {
+	selfId    types.NodeId
+	activeMap types.ClusterDomainsActiveMap
+	lock      sync.Mutex
+}
+
+// nodeWeight returns the number of votes of a node
+func nodeWeight(nodeInfo types.NodeInfo) uint {
+	if nodeInfo.Weight == 0 {
+		return 1
+	}
+	return nodeInfo.Weight
+}
+
+func (w *weightedQuorum) IsNodeInQuorum(localNodeInfoMap types.NodeInfoMap) bool {
+	return w.ExplainQuorum(localNodeInfoMap).InQuorum
+}
+
+func (w *weightedQuorum) ExplainQuorum(localNodeInfoMap types.NodeInfoMap) types.QuorumExplanation {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	e := newQuorumExplanation(types.QUORUM_PROVIDER_WEIGHTED)
+	for domain, isActive := range w.activeMap {
+		if isActive != types.CLUSTER_DOMAIN_STATE_ACTIVE {
+			e.InactiveDomains = append(e.InactiveDomains, domain)
+		}
+	}
+	for id, nodeInfo := range localNodeInfoMap {
+		if !nodeInfo.QuorumMember {
+			continue
+		}
+		if !w.isDomainActive(nodeInfo.ClusterDomain) && !isWitness(nodeInfo) {
+			ignoreMember(&e, id)
+			continue
+		}
+		if isInMaintenance(nodeInfo) {
+			// nodes in maintenance are neither required nor counted
+			ignoreMember(&e, id)
+			continue
+		}
+		e.TotalVotes += nodeWeight(nodeInfo)
+		countMember(&e, localNodeInfoMap, id, nodeInfo, nodeWeight(nodeInfo))
+	}
+	for domain, domainQuorum := range e.Domains {
+		domainQuorum.Active = w.isDomainActive(domain)
+		e.Domains[domain] = domainQuorum
+	}
+	majorityVerdict(&e)
+	sortExplanation(&e)
+
+	selfNodeInfo := localNodeInfoMap[w.selfId]
+	if !w.isDomainActive(selfNodeInfo.ClusterDomain) && !isWitness(selfNodeInfo) {
+		// This node is a part of deactivated failure domain
+		e.InQuorum = false
+		e.Reason = fmt.Sprintf("cluster domain %v of this node is not active", selfNodeInfo.ClusterDomain)
+	}
+	return e
+}
+
+// isDomainActive returns true if the failure domain is active. All the
+// domains are active if no activation map is provided.
+func (w *weightedQuorum) isDomainActive(ipDomain string) bool {
+	if len(w.activeMap) == 0 {
+		return true
+	}
+	return w.activeMap[ipDomain] == types.CLUSTER_DOMAIN_STATE_ACTIVE
+}
+
+func (w *weightedQuorum) IsDomainActive(inputDomain string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.isDomainActive(inputDomain)
+}
+
+func (w *weightedQuorum) UpdateNumOfQuorumMembers(quorumMembersMap types.ClusterDomainsQuorumMembersMap) {
+	// The quorum members and their weights are read from the node info map
+}
+
+func (w *weightedQuorum) UpdateClusterDomainsActiveMap(activeMap types.ClusterDomainsActiveMap) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	prevMap := w.activeMap
+	w.activeMap = make(types.ClusterDomainsActiveMap)
+
+	var stateChanged bool
+	for domain, isActive := range activeMap {
+		w.activeMap[domain] = isActive
+		if prevMap[domain] != isActive {
+			stateChanged = true
+			if isActive == types.CLUSTER_DOMAIN_STATE_ACTIVE {
+				logrus.Infof("gossip: Marking %v domain as active", domain)
+			} else {
+				logrus.Infof("gossip: Marking %v domain as inactive", domain)
+			}
+		}
+	}
+	return stateChanged
+}
+
+func (w *weightedQuorum) Type() types.QuorumProvider {
+	return types.QUORUM_PROVIDER_WEIGHTED
+}
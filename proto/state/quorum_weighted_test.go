@@ -0,0 +1,97 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/libopenstorage/gossip/types"
+	"github.com/stretchr/testify/require"
+)
+
+func getWeightedNodeInfoMap(weights ...uint) types.NodeInfoMap {
+	nodeInfoMap := make(types.NodeInfoMap)
+	for i, weight := range weights {
+		nodeInfoMap[types.NodeId(nodes[i])] = types.NodeInfo{
+			ClusterDomain: zones[i%2],
+			QuorumMember:  true,
+			Status:        types.NODE_STATUS_UP,
+			Weight:        weight,
+		}
+	}
+	return nodeInfoMap
+}
+
+func setNodeStatus(nodeInfoMap types.NodeInfoMap, id string, status types.NodeStatus) {
+	nodeInfo := nodeInfoMap[types.NodeId(id)]
+	nodeInfo.Status = status
+	nodeInfoMap[types.NodeId(id)] = nodeInfo
+}
+
+func TestWeightedQuorumPartition(t *testing.T) {
+	// n0 is a storage node with 3 votes. n1, n2 and n3 have one vote each.
+	localNodeInfoMap := getWeightedNodeInfoMap(3, 1, 1, 1)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_WEIGHTED)
+	require.Equal(t, types.QUORUM_PROVIDER_WEIGHTED, q.Type())
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+
+	// n0 and n1 on one side of the partition hold 4 of the 6 votes
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+
+	// n1, n2 and n3 hold 3 of the 6 votes which is not a strict majority
+	localNodeInfoMap = getWeightedNodeInfoMap(3, 1, 1, 1)
+	setNodeStatus(localNodeInfoMap, nodes[0], types.NODE_STATUS_DOWN)
+	q = NewQuorumProvider(types.NodeId(nodes[1]), types.QUORUM_PROVIDER_WEIGHTED)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// Zero weights count as one vote
+	localNodeInfoMap = getWeightedNodeInfoMap(0, 0, 0)
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_DOWN)
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+}
+
+func TestWeightedQuorumMaintenanceAndNonMembers(t *testing.T) {
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 1, 2, 2)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_WEIGHTED)
+	setNodeStatus(localNodeInfoMap, nodes[2], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// Nodes in maintenance are neither required nor counted
+	nodeInfo := localNodeInfoMap[types.NodeId(nodes[3])]
+	nodeInfo.Maintenance = true
+	localNodeInfoMap[types.NodeId(nodes[3])] = nodeInfo
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// Non quorum members do not vote
+	nodeInfo = localNodeInfoMap[types.NodeId(nodes[2])]
+	nodeInfo.QuorumMember = false
+	localNodeInfoMap[types.NodeId(nodes[2])] = nodeInfo
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+}
+
+func TestWeightedQuorumInactiveDomain(t *testing.T) {
+	// zone0 has n0 and n2, zone1 has n1 and n3
+	localNodeInfoMap := getWeightedNodeInfoMap(1, 5, 1, 5)
+	q := NewQuorumProvider(types.NodeId(nodes[0]), types.QUORUM_PROVIDER_WEIGHTED)
+	require.True(t, q.IsDomainActive(zones[0]), "Expected all domains active without an activation map")
+
+	setNodeStatus(localNodeInfoMap, nodes[1], types.NODE_STATUS_DOWN)
+	setNodeStatus(localNodeInfoMap, nodes[3], types.NODE_STATUS_DOWN)
+	require.False(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node not in quorum")
+
+	// Only the weight of the active domains counts
+	changed := q.UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap{
+		zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		zones[1]: types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	})
+	require.True(t, changed, "Expected activation map to change")
+	require.True(t, q.IsNodeInQuorum(localNodeInfoMap), "Expected node in quorum")
+
+	q = NewQuorumProvider(types.NodeId(nodes[1]), types.QUORUM_PROVIDER_WEIGHTED)
+	q.UpdateClusterDomainsActiveMap(types.ClusterDomainsActiveMap{
+		zones[0]: types.CLUSTER_DOMAIN_STATE_ACTIVE,
+		zones[1]: types.CLUSTER_DOMAIN_STATE_INACTIVE,
+	})
+	require.False(t, q.IsNodeInQuorum(getWeightedNodeInfoMap(1, 5, 1, 5)), "Expected inactive node not in quorum")
+}
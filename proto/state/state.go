@@ -12,6 +12,7 @@ type State interface {
 	// - DOWN
 	// - NOT_IN_QUORUM
 	// - SUSPECT_NOT_IN_QUORUM
+	// - MAINTENANCE
 
 	// SelfAlive is an event when memberlist indicates self node is alive
 	SelfAlive(nodeInfoMap types.NodeInfoMap) (State, error)
@@ -42,6 +43,15 @@ type State interface {
 		nodeInfoMap types.NodeInfoMap,
 	) (State, error)
 
+	// EnterMaintenance is an event triggered when this node is put in maintenance
+	EnterMaintenance() (State, error)
+
+	// ExitMaintenance is an event triggered when this node is taken out of
+	// maintenance
+	ExitMaintenance(
+		nodeInfoMap types.NodeInfoMap,
+	) (State, error)
+
 	// String
 	String() string
 
@@ -62,3 +62,13 @@ func (d *down) Timeout(
 ) (State, error) {
 	return d, nil
 }
+
+func (d *down) EnterMaintenance() (State, error) {
+	return d, nil
+}
+
+func (d *down) ExitMaintenance(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return d, nil
+}
@@ -0,0 +1,81 @@
+package state
+
+import (
+	"github.com/libopenstorage/gossip/types"
+)
+
+// maintenance is the state of a node which has been taken out for
+// maintenance. A node in maintenance does not participate in quorum
+// decisions and ignores all the events till it exits maintenance.
+type maintenance struct {
+	nodeStatus     types.NodeStatus
+	stateEvent     chan types.StateEvent
+	quorumProvider Quorum
+}
+
+func GetMaintenance(
+	stateEvent chan types.StateEvent,
+	quorumProvider Quorum,
+) State {
+	return &maintenance{
+		nodeStatus:     types.NODE_STATUS_MAINTENANCE,
+		stateEvent:     stateEvent,
+		quorumProvider: quorumProvider,
+	}
+}
+
+func (m *maintenance) String() string {
+	return "NODE_STATUS_MAINTENANCE"
+}
+
+func (m *maintenance) NodeStatus() types.NodeStatus {
+	return m.nodeStatus
+}
+
+func (m *maintenance) SelfAlive(localNodeInfoMap types.NodeInfoMap) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) NodeAlive(localNodeInfoMap types.NodeInfoMap) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) SelfLeave() (State, error) {
+	return GetDown(m.stateEvent, m.quorumProvider), nil
+}
+
+func (m *maintenance) NodeLeave(localNodeInfoMap types.NodeInfoMap) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) UpdateClusterSize(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) UpdateClusterDomainsActiveMap(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) Timeout(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) EnterMaintenance() (State, error) {
+	return m, nil
+}
+
+func (m *maintenance) ExitMaintenance(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	if !m.quorumProvider.IsNodeInQuorum(localNodeInfoMap) {
+		return GetNotInQuorum(m.stateEvent, m.quorumProvider), nil
+	} else {
+		return GetUp(m.stateEvent, m.quorumProvider), nil
+	}
+}
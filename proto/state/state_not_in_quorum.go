@@ -82,3 +82,13 @@ func (niq *notInQuorum) Timeout(
 ) (State, error) {
 	return niq, nil
 }
+
+func (niq *notInQuorum) EnterMaintenance() (State, error) {
+	return GetMaintenance(niq.stateEvent, niq.quorumProvider), nil
+}
+
+func (niq *notInQuorum) ExitMaintenance(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return niq, nil
+}
@@ -83,3 +83,13 @@ func (siq *suspectNotInQuorum) Timeout(
 		return GetUp(siq.stateEvent, siq.quorumProvider), nil
 	}
 }
+
+func (siq *suspectNotInQuorum) EnterMaintenance() (State, error) {
+	return GetMaintenance(siq.stateEvent, siq.quorumProvider), nil
+}
+
+func (siq *suspectNotInQuorum) ExitMaintenance(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return siq, nil
+}
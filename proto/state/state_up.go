@@ -78,3 +78,13 @@ func (u *up) Timeout(
 ) (State, error) {
 	return u, nil
 }
+
+func (u *up) EnterMaintenance() (State, error) {
+	return GetMaintenance(u.stateEvent, u.quorumProvider), nil
+}
+
+func (u *up) ExitMaintenance(
+	localNodeInfoMap types.NodeInfoMap,
+) (State, error) {
+	return u, nil
+}
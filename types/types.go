@@ -1,6 +1,7 @@
 package types
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -43,6 +44,9 @@ type ClusterDomainsActiveMap map[string]ClusterDomainState
 // of quorum members in that domain
 type ClusterDomainsQuorumMembersMap map[string]int
 
+// MemberlistState is the state of a node as seen by memberlist
+type MemberlistState string
+
 // Constant Definitions
 
 const (
@@ -56,6 +60,31 @@ const (
 	GOSSIP_VERSION_2             string        = "v2"
 )
 
+const (
+	DEFAULT_JOIN_RETRY_INITIAL_BACKOFF time.Duration = 1 * time.Second
+	DEFAULT_JOIN_RETRY_MAX_BACKOFF     time.Duration = 1 * time.Minute
+	DEFAULT_JOIN_RETRY_MULTIPLIER      float64       = 2
+	DEFAULT_RESOLVE_INTERVAL           time.Duration = 30 * time.Second
+	DEFAULT_DISCOVERY_INTERVAL         time.Duration = 30 * time.Second
+	DEFAULT_ARBITER_LEASE_TTL          time.Duration = 10 * time.Second
+	DEFAULT_STATE_HISTORY_SIZE         int           = 100
+	DEFAULT_FENCE_HOOK_DEADLINE        time.Duration = 30 * time.Second
+)
+
+const (
+	DEFAULT_DOMAIN_ACTIVATION_INTERVAL        time.Duration = 5 * time.Second
+	DEFAULT_DOMAIN_ACTIVATION_STABLE_INTERVAL time.Duration = 30 * time.Second
+	DEFAULT_DOMAIN_ACTIVATION_HOLD_DOWN       time.Duration = 2 * time.Minute
+)
+
+const (
+	DEFAULT_FLAP_PENALTY            float64       = 1000
+	DEFAULT_FLAP_SUPPRESS_THRESHOLD float64       = 2000
+	DEFAULT_FLAP_REUSE_THRESHOLD    float64       = 750
+	DEFAULT_FLAP_MAX_PENALTY        float64       = 4000
+	DEFAULT_FLAP_HALF_LIFE          time.Duration = 1 * time.Minute
+)
+
 const (
 	NODE_STATUS_INVALID NodeStatus = iota
 	NODE_STATUS_UP
@@ -64,6 +93,12 @@ const (
 	NODE_STATUS_NOT_IN_QUORUM
 	NODE_STATUS_SUSPECT_NOT_IN_QUORUM
 	NODE_STATUS_SUSPECT_DOWN
+	NODE_STATUS_DAMPED
+	NODE_STATUS_MAINTENANCE
+	NODE_STATUS_LEFT
+	// NODE_STATUS_WITNESS is the status of a witness node which is alive
+	// and in quorum. Witnesses vote in quorum but never become UP.
+	NODE_STATUS_WITNESS
 )
 
 const (
@@ -74,12 +109,38 @@ const (
 	UPDATE_CLUSTER_SIZE
 	TIMEOUT
 	UPDATE_CLUSTER_DOMAINS_ACTIVE_MAP
+	ENTER_MAINTENANCE
+	EXIT_MAINTENANCE
+	QUORUM_STABLE
 )
 
+var stateEventNames = map[StateEvent]string{
+	SELF_ALIVE:                        "SELF_ALIVE",
+	NODE_ALIVE:                        "NODE_ALIVE",
+	SELF_LEAVE:                        "SELF_LEAVE",
+	NODE_LEAVE:                        "NODE_LEAVE",
+	UPDATE_CLUSTER_SIZE:               "UPDATE_CLUSTER_SIZE",
+	TIMEOUT:                           "TIMEOUT",
+	UPDATE_CLUSTER_DOMAINS_ACTIVE_MAP: "UPDATE_CLUSTER_DOMAINS_ACTIVE_MAP",
+	ENTER_MAINTENANCE:                 "ENTER_MAINTENANCE",
+	EXIT_MAINTENANCE:                  "EXIT_MAINTENANCE",
+	QUORUM_STABLE:                     "QUORUM_STABLE",
+}
+
+func (e StateEvent) String() string {
+	if name, ok := stateEventNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("StateEvent(%d)", uint8(e))
+}
+
 const (
 	QUORUM_PROVIDER_DEFAULT QuorumProvider = iota
 	QUORUM_PROVIDER_FAILURE_DOMAINS
 	QUORUM_PROVIDER_NOOP
+	QUORUM_PROVIDER_WEIGHTED
+	QUORUM_PROVIDER_ARBITER
+	QUORUM_PROVIDER_DYNAMIC
 )
 
 const (
@@ -87,14 +148,33 @@ const (
 	CLUSTER_DOMAIN_STATE_INACTIVE = ClusterDomainState("Inactive")
 )
 
+const (
+	// MEMBERLIST_STATE_ALIVE indicates that memberlist considers the node
+	// alive or suspects it to have failed
+	MEMBERLIST_STATE_ALIVE = MemberlistState("Alive")
+	// MEMBERLIST_STATE_DEAD indicates that the node is not an alive member
+	// of memberlist
+	MEMBERLIST_STATE_DEAD = MemberlistState("Dead")
+	// MEMBERLIST_STATE_UNKNOWN indicates that this node is not gossiping
+	MEMBERLIST_STATE_UNKNOWN = MemberlistState("Unknown")
+)
+
 // NodeUpdate object is used for externally updating a node in gossip
 type NodeUpdate struct {
-	// Addr is the contact address for the node
+	// Addr is the contact address for the node in host:port form. The host
+	// can either be an ip address or a DNS name.
 	Addr string
 	// QuorumMember is true if node participates in quorum decisions
 	QuorumMember bool
 	// ClusterDomain of the node
 	ClusterDomain string
+	// Weight is the number of quorum votes of the node with the weighted
+	// quorum provider. A zero weight counts as one vote.
+	Weight uint
+	// Witness is true if the node only votes in quorum. Witnesses are
+	// always quorum members, carry no store data and never run workloads.
+	// A witness gives its vote to one side of a partition at a time.
+	Witness bool
 }
 
 // NodeMetaInfo object is the node metadata information that gets stored in
@@ -103,14 +183,38 @@ type NodeUpdate struct {
 type NodeMetaInfo struct {
 	// ClusterId of the gossip cluster
 	ClusterId string
+	// PrevClusterId is the old cluster id which the node still accepts
+	// while it migrates to ClusterId
+	PrevClusterId string
 	// GossipVersion is the version of gossip protocol
 	GossipVersion string
+	// MinGossipVersion is the lowest gossip version the node is compatible
+	// with. It is empty for nodes which are only compatible with their
+	// own GossipVersion.
+	MinGossipVersion string
+	// MaxGossipVersion is the highest gossip version the node is
+	// compatible with
+	MaxGossipVersion string
 	// Id is the node id
 	Id NodeId
 	// GenNumber of the object
 	GenNumber uint64
 	// LastUpdateTs is the last updated timestamp for this object
 	LastUpdateTs time.Time
+	// Leaving is true if the node is gracefully leaving the cluster
+	Leaving bool
+	// LeaveReason is the reason provided by the node for leaving the cluster
+	LeaveReason string
+	// Labels are the user defined key/value labels of the node
+	Labels map[string]string
+	// LabelsTs is the time at which the node last updated its labels
+	LabelsTs time.Time
+	// Addr is the ip:port address advertised by the node
+	Addr string
+	// StartTs is the time at which this instance of the node started
+	StartTs time.Time
+	// Maintenance is true if the node is in maintenance
+	Maintenance bool
 }
 
 // NodeInfo is the node object that is stored for each node
@@ -132,8 +236,41 @@ type NodeInfo struct {
 	QuorumMember bool
 	// ClusterDomain indicates the cluster domain in which this node lies
 	ClusterDomain string
-	// Addr is the connection address for this node
+	// Weight is the number of quorum votes of this node with the
+	// weighted quorum provider
+	Weight uint
+	// Witness indicates if this node is a tie-breaker which only votes
+	// in quorum
+	Witness bool
+	// WitnessVote is the quorum member to which a witness gives its vote.
+	// The vote of the witness only counts on the side of a partition
+	// which can see this member.
+	WitnessVote NodeId
+	// Addr is the connection address for this node. It is the address
+	// advertised by the node, or else the configured address.
 	Addr string
+	// ConfiguredAddr is the address of this node provided through
+	// UpdateCluster. It can have a DNS name which is resolved again when
+	// the node moves to another ip.
+	ConfiguredAddr string
+	// Reachable is the list of peer nodes this node can reach as seen by
+	// its memberlist
+	Reachable []NodeId
+	// ReachableTs is the time at which Reachable was last computed
+	ReachableTs time.Time
+	// Maintenance indicates if this node is in maintenance. Nodes in
+	// maintenance do not participate in quorum calculations.
+	Maintenance bool
+	// Leaving is true if the node is gracefully leaving or has left
+	// the cluster
+	Leaving bool
+	// LeaveReason is the reason provided by the node for leaving the cluster
+	LeaveReason string
+	// Labels are the user defined key/value labels of the node as
+	// published in its memberlist meta data
+	Labels map[string]string
+	// LabelsTs is the time at which the node last updated its labels
+	LabelsTs time.Time
 }
 
 // NodeValue is the node object that is returned to the callers of gossip.
@@ -151,6 +288,83 @@ func (n NodeInfo) String() string {
 		n.Id, n.LastUpdateTs, n.Status, n.Value)
 }
 
+// Member is a node of the cluster as seen by memberlist and the gossip store
+type Member struct {
+	// Id of the node
+	Id NodeId
+	// Addr is the ip address of the node
+	Addr string
+	// Port is the gossip port of the node
+	Port uint16
+	// Status of the node as seen by gossip on this node
+	Status NodeStatus
+	// MemberlistState is the state of the node as seen by memberlist
+	MemberlistState MemberlistState
+	// ClusterDomain indicates the cluster domain in which this node lies
+	ClusterDomain string
+	// QuorumMember indicates if this node participates in quorum calculations
+	QuorumMember bool
+	// Witness indicates if this node only votes in quorum
+	Witness bool
+	// GenNumber of the node's gossip data
+	GenNumber uint64
+	// LastHeardTs is the time at which this node last heard from or
+	// about the node. It is zero if nothing has been heard yet.
+	LastHeardTs time.Time
+	// Labels are the user defined key/value labels of the node
+	Labels map[string]string
+}
+
+// MemberFilter selects the members returned by Members. Empty fields
+// match all the members.
+type MemberFilter struct {
+	// Ids selects the members with one of the given ids
+	Ids []NodeId
+	// Statuses selects the members with one of the given statuses
+	Statuses []NodeStatus
+	// MemberlistStates selects the members with one of the given
+	// memberlist states
+	MemberlistStates []MemberlistState
+	// ClusterDomain selects the members in the given cluster domain
+	ClusterDomain string
+	// QuorumMember if set selects the members whose quorum membership
+	// matches its value
+	QuorumMember *bool
+	// Labels selects the members which have all the given labels
+	Labels map[string]string
+}
+
+// NodeLink is a directed connection between two nodes
+type NodeLink struct {
+	// From is the node observing the link
+	From NodeId
+	// To is the node being observed
+	To NodeId
+}
+
+// ReachabilityMatrix is a map of a node to the set of peer nodes it can reach
+type ReachabilityMatrix map[NodeId]map[NodeId]bool
+
+// ReachabilityReport is the cluster wide view of connectivity between nodes
+// built from the reachability views gossiped by every node
+type ReachabilityReport struct {
+	// Matrix holds the reachability view of every node which has published
+	// a recent view
+	Matrix ReachabilityMatrix
+	// Groups is the list of suspected partition groups. Nodes in the same
+	// group can reach the same set of peers.
+	Groups [][]NodeId
+	// AsymmetricLinks is the list of links where From can reach To but
+	// To cannot reach From
+	AsymmetricLinks []NodeLink
+	// Unreachable is the list of nodes which no node can reach and which
+	// have not published a recent view
+	Unreachable []NodeId
+	// PartitionSuspected is true if the nodes do not share the same view
+	// of the cluster
+	PartitionSuspected bool
+}
+
 // GossipIntervals object defines the different tuning parameters for gossip intervals and timeouts
 type GossipIntervals struct {
 	// GossipInterval is the time interval within which the nodes gossip
@@ -163,18 +377,73 @@ type GossipIntervals struct {
 	ProbeInterval time.Duration
 	// ProbeTimeout used to determine if a node is down. Should be atleast twice the RTT of network
 	ProbeTimeout time.Duration
-	// QuorumTimeout is the timeout for which a node will stay in the SUSPECT_NOT_IN_QUORUM
-	// and then transition to NOT_IN_QUORUM (Not UP) if quorum is not satisfied
+	// QuorumTimeout is the timeout of the SUSPECT_NOT_IN_QUORUM to
+	// NOT_IN_QUORUM transition. A node which loses quorum stays in
+	// SUSPECT_NOT_IN_QUORUM for this long and then transitions to
+	// NOT_IN_QUORUM (Not UP) if quorum is not satisfied.
 	QuorumTimeout time.Duration
+	// QuorumRecoveryInterval is the timeout of the NOT_IN_QUORUM to UP
+	// transition. Quorum must hold for this long before a node which lost
+	// quorum moves back to UP. A zero value moves the node back to UP as
+	// soon as quorum is regained.
+	//
+	// These are the only timed transitions of a node. All the other
+	// transitions, including SUSPECT_NOT_IN_QUORUM back to UP, happen as
+	// soon as the event causing them is seen, and a node never moves from
+	// NOT_IN_QUORUM to DOWN on its own.
+	QuorumRecoveryInterval time.Duration
 	// SuspicionMult is the multiplier for determining the time an
 	// inaccessible node is considered suspect before declaring it dead.
 	SuspicionMult int
 }
 
+// FlapDampingConfig object defines the tuning parameters for damping peer
+// nodes which keep flapping between up and down
+type FlapDampingConfig struct {
+	// Penalty is added to a node's penalty score every time it goes down.
+	// A zero value disables flap damping.
+	Penalty float64
+	// SuppressThreshold is the penalty score above which a node is damped.
+	// It defaults to DEFAULT_FLAP_SUPPRESS_THRESHOLD.
+	SuppressThreshold float64
+	// ReuseThreshold is the penalty score below which a damped node is
+	// released from the damped state. It must be lower than the suppress
+	// threshold and defaults to DEFAULT_FLAP_REUSE_THRESHOLD.
+	ReuseThreshold float64
+	// MaxPenalty is the maximum penalty score a node can accumulate. It
+	// defaults to DEFAULT_FLAP_MAX_PENALTY.
+	MaxPenalty float64
+	// HalfLife is the time in which a node's penalty score decays by half.
+	// It defaults to DEFAULT_FLAP_HALF_LIFE.
+	HalfLife time.Duration
+}
+
+// JoinRetryConfig object defines how a node retries joining its peers when
+// the join fails and how often it re-joins peers which it cannot see
+type JoinRetryConfig struct {
+	// Enabled retries failed joins in the background instead of failing
+	// Start. It is implied by a non-zero InitialBackoff.
+	Enabled bool
+	// InitialBackoff is the time to wait before retrying a failed join.
+	// It defaults to DEFAULT_JOIN_RETRY_INITIAL_BACKOFF.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time to wait between join retries.
+	// It defaults to DEFAULT_JOIN_RETRY_MAX_BACKOFF.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after every
+	// failed join. It defaults to DEFAULT_JOIN_RETRY_MULTIPLIER.
+	Multiplier float64
+	// RejoinInterval is the time interval at which the node re-joins the
+	// known peer nodes which are not alive. A zero value disables the
+	// periodic re-join.
+	RejoinInterval time.Duration
+}
+
 // GossipNodeConfiguration is the peer node configuration with which gossip on this
 // node can start
 type GossipNodeConfiguration struct {
-	// KnownUrl is the ip of this peer node
+	// KnownUrl is the host:port address of this peer node. The host can
+	// either be an ip address or a DNS name.
 	KnownUrl string
 	// ClusterDomain is the failure domain of this peer node
 	ClusterDomain string
@@ -189,6 +458,325 @@ type GossipStartConfiguration struct {
 	ActiveMap ClusterDomainsActiveMap
 	// QuorumProviderType indicates which quorum calculation algorithm to use
 	QuorumProviderType QuorumProvider
+	// FlapDamping is the configuration for damping flapping peer nodes.
+	// Flap damping is disabled if no penalty is configured.
+	FlapDamping FlapDampingConfig
+	// JoinRetry is the configuration for retrying failed joins and
+	// periodically re-joining peer nodes. If join retries are disabled
+	// Start fails when the node cannot join its peers.
+	JoinRetry JoinRetryConfig
+	// Discoverer is an optional source of seed nodes which is queried in
+	// addition to Nodes when joining the cluster. It is then polled for new
+	// nodes on every re-join, or every DiscoveryInterval if the periodic
+	// re-join is disabled.
+	Discoverer Discoverer
+	// DiscoveryInterval is the time interval at which the Discoverer is
+	// polled when the periodic re-join is disabled. It defaults to
+	// DEFAULT_DISCOVERY_INTERVAL.
+	DiscoveryInterval time.Duration
+	// Labels are the initial key/value labels of this node. They are
+	// published to the other nodes along with the membership information.
+	Labels map[string]string
+	// AdvertiseAddr is the ip:port address advertised to the other nodes.
+	// It is needed when the bind address is not reachable by the other
+	// nodes, like behind a NAT. If not provided the bind address is
+	// advertised, or the address of a routable interface if gossip is
+	// bound to all the interfaces.
+	AdvertiseAddr string
+	// ResolveInterval is the time interval at which the DNS names in the
+	// configured peer addresses are resolved again. It defaults to
+	// DEFAULT_RESOLVE_INTERVAL and a negative value disables the periodic
+	// resolution. Names are still resolved again on failed pings and when
+	// a peer node goes down.
+	ResolveInterval time.Duration
+	// OnDuplicateNodeId is an optional callback which is invoked when two
+	// nodes are detected gossiping with the same node id
+	OnDuplicateNodeId func(err *DuplicateNodeIdError)
+	// RefuseDuplicateNodeId makes this node stop gossiping if another node
+	// with the same node id started before it. Start returns a
+	// DuplicateNodeIdError if the duplicate is detected while joining.
+	RefuseDuplicateNodeId bool
+	// MinGossipVersion and MaxGossipVersion are the range of gossip versions
+	// this node is compatible with. Nodes gossip with each other if their
+	// ranges overlap, which allows upgrading the gossip version one node at
+	// a time. Both default to the gossip version of this node.
+	MinGossipVersion string
+	MaxGossipVersion string
+	// OnClusterIdMigrated is an optional callback which is invoked when
+	// all the nodes advertise the new cluster id of a cluster id migration
+	OnClusterIdMigrated func(clusterId string)
+	// Federation makes this node a gateway of the cluster in a federation
+	// of clusters. It is nil for nodes which are not gateways.
+	Federation *FederationConfig
+	// Arbiter breaks ties with the arbiter quorum provider. It is required
+	// for that provider and ignored by the others.
+	Arbiter Arbiter
+	// ArbiterLeaseTTL is the time for which the arbiter lease is held
+	// without renewal. It defaults to DEFAULT_ARBITER_LEASE_TTL.
+	ArbiterLeaseTTL time.Duration
+	// StateHistory is the configuration of the history of the state
+	// transitions of this node
+	StateHistory StateHistoryConfig
+	// OnFenceHookTimeout is an optional callback which is invoked when a
+	// fencing hook misses its deadline
+	OnFenceHookTimeout func(hook string, action FenceAction)
+	// DomainActivation is the configuration of the policy which activates
+	// and deactivates the cluster domains automatically
+	DomainActivation DomainActivationConfig
+}
+
+// DomainHealth is the health of a cluster domain as seen by this node
+type DomainHealth struct {
+	// Active is true if the domain is currently active
+	Active bool
+	// QuorumMembers is the number of quorum members in the domain
+	QuorumMembers int
+	// AliveMembers is the number of quorum members in the domain which are
+	// alive, irrespective of whether they are in quorum
+	AliveMembers int
+	// Witnesses is the number of witnesses in the domain
+	Witnesses int
+	// AliveWitnesses is the number of witnesses in the domain which are alive
+	AliveWitnesses int
+}
+
+// DomainActivationPolicy decides which cluster domains should be active
+type DomainActivationPolicy interface {
+	// ActiveMap returns the active map for the given health of the cluster
+	// domains. The domains missing from the returned map keep their current
+	// state. A nil map keeps the current active map.
+	ActiveMap(health map[string]DomainHealth) ClusterDomainsActiveMap
+	// String returns the name of the policy
+	String() string
+}
+
+// DomainActivationConfig is the configuration of the automatic activation
+// of cluster domains. A change proposed by the policy is applied only if
+// the policy keeps proposing it for StableInterval, and not earlier than
+// HoldDown after the previous change. A call to
+// UpdateClusterDomainsActiveMap overrides the policy till it is resumed.
+type DomainActivationConfig struct {
+	// Policy decides which domains are active. The automatic activation is
+	// disabled if it is nil.
+	Policy DomainActivationPolicy
+	// Interval is the time interval at which the policy is evaluated. It
+	// defaults to DEFAULT_DOMAIN_ACTIVATION_INTERVAL.
+	Interval time.Duration
+	// StableInterval is the time for which a change must be proposed
+	// before it is applied. It defaults to
+	// DEFAULT_DOMAIN_ACTIVATION_STABLE_INTERVAL.
+	StableInterval time.Duration
+	// HoldDown is the minimum time between two changes of the active map.
+	// It defaults to DEFAULT_DOMAIN_ACTIVATION_HOLD_DOWN.
+	HoldDown time.Duration
+}
+
+// FenceAction is the action a fencing hook is invoked for
+type FenceAction string
+
+const (
+	// FENCE_ACTION_FENCE is taken when this node drops out of quorum or
+	// its cluster domain is deactivated
+	FENCE_ACTION_FENCE = FenceAction("Fence")
+	// FENCE_ACTION_UNFENCE is taken when this node regains quorum after
+	// being fenced
+	FENCE_ACTION_UNFENCE = FenceAction("Unfence")
+)
+
+// FenceHook is invoked when this node needs to be fenced or unfenced
+type FenceHook struct {
+	// Name identifies the hook
+	Name string
+	// Deadline is the time within which the hook should return. It
+	// defaults to DEFAULT_FENCE_HOOK_DEADLINE.
+	Deadline time.Duration
+	// Fn fences or unfences the node. The context expires at the deadline
+	// and Fn should return soon after, as the next hook is not run till it
+	// returns.
+	Fn func(ctx context.Context, action FenceAction, status NodeStatus) error
+}
+
+// StateHistoryConfig is the configuration of the history of state transitions
+type StateHistoryConfig struct {
+	// Size is the number of transitions kept in memory. It defaults to
+	// DEFAULT_STATE_HISTORY_SIZE.
+	Size int
+	// Path is an optional file to which the transitions are appended as
+	// JSON lines. The last transitions in the file are loaded on start, so
+	// that the history survives restarts of the process. The file is
+	// compacted to the last Size transitions on start and whenever it
+	// holds twice as many.
+	Path string
+}
+
+// StateTransition is a change in the status of this node
+type StateTransition struct {
+	// Ts is the time of the transition
+	Ts time.Time
+	// Event is the event which caused the transition
+	Event StateEvent
+	// PrevStatus is the status of the node before the transition
+	PrevStatus NodeStatus
+	// NextStatus is the status of the node after the transition
+	NextStatus NodeStatus
+	// Quorum is the quorum verdict after the transition
+	Quorum QuorumExplanation
+	// NumPeers is the number of peer nodes
+	NumPeers int
+	// NumPeersUp is the number of peer nodes which are up
+	NumPeersUp int
+}
+
+// FederationConfig is the configuration of a gateway node which shares a
+// summary of its cluster with the gateway nodes of other clusters
+type FederationConfig struct {
+	// Addr is the ip:port address on which the gateway gossips with the
+	// gateways of the other clusters
+	Addr string
+	// Gateways is the list of host:port addresses of known gateway nodes
+	// of the other clusters
+	Gateways []string
+	// ExportedKeys are the store keys whose values are shared with the
+	// other clusters
+	ExportedKeys []StoreKey
+	// PushPullInterval is the time interval at which the gateways exchange
+	// the cluster summaries. It defaults to the memberlist WAN interval.
+	PushPullInterval time.Duration
+}
+
+// ClusterSummary is the summarized state of a cluster which the gateway
+// nodes of a federation exchange
+type ClusterSummary struct {
+	// ClusterId of the cluster
+	ClusterId string
+	// GatewayId is the id of the gateway node which built the summary
+	GatewayId NodeId
+	// NumNodes is the number of nodes in the cluster
+	NumNodes int
+	// NumNodesUp is the number of nodes which are up as seen by the gateway
+	NumNodesUp int
+	// GatewayStatus is the quorum status of the gateway node
+	GatewayStatus NodeStatus
+	// Values are the values of the exported store keys
+	Values map[StoreKey]NodeValueMap
+	// LastUpdateTs is the time at which the summary was built
+	LastUpdateTs time.Time
+}
+
+// ClusterIdMigrationStatus is the progress of a cluster id migration
+type ClusterIdMigrationStatus struct {
+	// OldClusterId is the cluster id being migrated from
+	OldClusterId string
+	// NewClusterId is the cluster id being migrated to
+	NewClusterId string
+	// Deadline is the end of the window in which the old cluster id
+	// is accepted
+	Deadline time.Time
+	// InProgress is true while the old cluster id is accepted
+	InProgress bool
+	// NodeClusterIds is the cluster id advertised by every node. It is
+	// empty for nodes which have not been heard from.
+	NodeClusterIds map[NodeId]string
+	// Complete is true if all the nodes advertise the new cluster id
+	Complete bool
+}
+
+// NodeVersion is the gossip version information of a node
+type NodeVersion struct {
+	// GossipVersion is the gossip version the node runs
+	GossipVersion string
+	// MinGossipVersion is the lowest gossip version the node is compatible with
+	MinGossipVersion string
+	// MaxGossipVersion is the highest gossip version the node is compatible with
+	MaxGossipVersion string
+	// NegotiatedVersion is the highest gossip version supported by both
+	// this node and the node
+	NegotiatedVersion string
+}
+
+// DuplicateNodeIdError is reported when two nodes gossip with the same node id
+type DuplicateNodeIdError struct {
+	// NodeId is the node id claimed by both the nodes
+	NodeId NodeId
+	// ExistingAddr is the address at which the node id is already known
+	ExistingAddr string
+	// OtherAddr is the address of the other node claiming the node id
+	OtherAddr string
+}
+
+func (e *DuplicateNodeIdError) Error() string {
+	return fmt.Sprintf("gossip: duplicate node id %v: known at %v, also claimed by %v",
+		e.NodeId, e.ExistingAddr, e.OtherAddr)
+}
+
+// Discoverer defines an interface for discovering the addresses of the
+// seed nodes with which gossip should join
+type Discoverer interface {
+	// Discover returns the list of seed node addresses in ip:port form
+	Discover() ([]string, error)
+	// String returns a description of the discoverer
+	String() string
+}
+
+// Arbiter breaks ties in quorum decisions by granting a lease to a single
+// side of the cluster at a time
+type Arbiter interface {
+	// Acquire acquires or renews the lease on behalf of a side of the
+	// cluster for the ttl. A side is the set of the ids of its up nodes. As
+	// the members of a side change over time, any side which shares a node
+	// with the holder renews the lease and becomes its holder. It returns
+	// false if another side owns an unexpired lease.
+	Acquire(side []string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if it is owned by the side
+	Release(side []string) error
+	// String returns a description of the arbiter
+	String() string
+}
+
+// DomainQuorum is the share of a cluster domain in the quorum of a node
+type DomainQuorum struct {
+	// Active is true if the domain is active
+	Active bool
+	// TotalVotes is the number of votes of the quorum members in the domain
+	TotalVotes uint
+	// UpVotes is the number of votes of the up quorum members in the domain
+	UpVotes uint
+	// RequiredVotes is the number of up votes the domain needs for quorum,
+	// given the up votes of the other domains
+	RequiredVotes uint
+}
+
+// QuorumExplanation describes how the quorum provider of a node decided
+// whether the node is in quorum
+type QuorumExplanation struct {
+	// Provider is the type of the quorum provider
+	Provider QuorumProvider
+	// Status is the status of the node
+	Status NodeStatus
+	// InQuorum is the verdict of the quorum provider
+	InQuorum bool
+	// Reason describes the verdict
+	Reason string
+	// TotalVotes is the number of votes which count towards quorum. Every
+	// quorum member has a single vote unless weights are in use.
+	TotalVotes uint
+	// RequiredVotes is the number of votes needed for quorum
+	RequiredVotes uint
+	// UpVotes is the number of votes of the up quorum members
+	UpVotes uint
+	// Domains are the cluster domains of the quorum members
+	Domains map[string]DomainQuorum
+	// InactiveDomains are the cluster domains which are not active
+	InactiveDomains []string
+	// UpMembers are the quorum members which were counted as up
+	UpMembers []NodeId
+	// MissingMembers are the quorum members which were counted as missing
+	// along with their statuses. A witness which gives its vote to the
+	// other side of a partition is missing with the witness status.
+	MissingMembers map[NodeId]NodeStatus
+	// IgnoredMembers are the quorum members which are neither required nor
+	// counted, like the nodes in maintenance or in inactive domains
+	IgnoredMembers []NodeId
 }
 
 // Used by the Gossip protocol